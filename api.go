@@ -0,0 +1,390 @@
+/*
+
+
+api.go implementation of an HTTP API for rendering genomes.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxRenderPixels is the largest number of pixels a single request can ask
+// the rendering API for.
+const maxRenderPixels = 4096 * 4096
+
+// maxRenderSamples is the largest number of samples per pixel along each axis
+// a single request can ask for.
+const maxRenderSamples = 8
+
+// compiledDPPN is a renderer of a DPPN in the cache, along with a lock, since
+// a renderer keeps the signals of its DPPNs in their nodes and cannot render
+// two images at once.
+type compiledDPPN struct {
+	key      string
+	renderer *Renderer
+	mu       sync.Mutex
+}
+
+// DPPNCache is an LRU cache of DPPNs compiled from genomes, keyed by genome
+// ID.
+type DPPNCache struct {
+	Capacity int                      // maximum number of cached DPPNs
+	entries  map[string]*list.Element // cached DPPNs by genome ID
+	order    *list.List               // cached DPPNs, most recently used first
+	mu       sync.Mutex
+}
+
+// NewDPPNCache creates a new empty cache, given its capacity.
+func NewDPPNCache(capacity int) *DPPNCache {
+	return &DPPNCache{
+		Capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Get returns the compiled DPPN of the argument genome, compiling it and
+// evicting the least recently used DPPN if it is not in the cache.
+func (c *DPPNCache) Get(key string, g *Genome) (*compiledDPPN, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		c.order.MoveToFront(e)
+		return e.Value.(*compiledDPPN), nil
+	}
+
+	r, err := NewRenderer(g, 0)
+	if err != nil {
+		return nil, err
+	}
+	entry := &compiledDPPN{key: key, renderer: r}
+	c.entries[key] = c.order.PushFront(entry)
+
+	for c.order.Len() > c.Capacity {
+		last := c.order.Back()
+		c.order.Remove(last)
+		delete(c.entries, last.Value.(*compiledDPPN).key)
+	}
+
+	return entry, nil
+}
+
+// RenderServer is an HTTP API that renders uploaded genomes, given render
+// parameters in the query string.
+type RenderServer struct {
+	Genomes    map[string]*Genome // uploaded genomes by ID
+	MaxGenomes int                // maximum number of kept genomes
+	Store      Store              // store of genomes by ID, if any
+	Cache      *DPPNCache         // compiled DPPNs
+	ids        []string           // IDs of kept genomes, oldest first
+	mu         sync.RWMutex
+}
+
+// NewRenderServer creates a new rendering API without any genomes, given the
+// number of compiled DPPNs to cache and the number of genomes to keep.
+func NewRenderServer(cacheSize, maxGenomes int) *RenderServer {
+	return &RenderServer{
+		Genomes:    make(map[string]*Genome),
+		MaxGenomes: maxGenomes,
+		Cache:      NewDPPNCache(cacheSize),
+	}
+}
+
+// keep keeps the argument genome, forgetting the oldest kept genomes once
+// there are more than MaxGenomes. Forgotten genomes in the store are read
+// from it again when they are rendered.
+func (s *RenderServer) keep(id string, g *Genome) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.Genomes[id]; !ok {
+		s.ids = append(s.ids, id)
+	}
+	s.Genomes[id] = g
+	for len(s.ids) > s.MaxGenomes {
+		delete(s.Genomes, s.ids[0])
+		s.ids = s.ids[1:]
+	}
+}
+
+// Upload reads a genome in the format written by Export, stores it, and
+// returns its ID.
+func (s *RenderServer) Upload(r io.Reader) (string, error) {
+	g, err := ImportGenome(r, 0)
+	if err != nil {
+		return "", err
+	}
+	// the color space of each render is validated with its request
+	if err := (ColorSpace{}).Validate(g.NumOutputs); err != nil {
+		return "", err
+	}
+	if _, err := NewDPPN(g, 1); err != nil {
+		return "", err
+	}
+
+	id := g.Hash()
+	s.keep(id, g)
+	return id, nil
+}
+
+// find returns the uploaded genome with the argument ID, or the genome from
+// the store if it has not been uploaded.
+func (s *RenderServer) find(id string) (*Genome, error) {
+	s.mu.RLock()
+	g, ok := s.Genomes[id]
+	s.mu.RUnlock()
+	if ok {
+		return g, nil
+	}
+	if s.Store == nil {
+		return nil, ErrNotFound
+	}
+
+	r, err := s.Store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	g, err = r.Decode(0)
+	if err != nil {
+		return nil, err
+	}
+	if err := (ColorSpace{}).Validate(g.NumOutputs); err != nil {
+		return nil, err
+	}
+
+	s.keep(id, g)
+	return g, nil
+}
+
+// parseRenderOptions reads render options from the argument query, with the
+// defaults of a 256 x 256 image of the 256 x 256 domain.
+func parseRenderOptions(r *http.Request) (*RenderOptions, error) {
+	q := r.URL.Query()
+
+	integer := func(key string, def int) (int, error) {
+		if v := q.Get(key); v != "" {
+			return strconv.Atoi(v)
+		}
+		return def, nil
+	}
+	float := func(key string, def float64) (float64, error) {
+		if v := q.Get(key); v != "" {
+			return strconv.ParseFloat(v, 64)
+		}
+		return def, nil
+	}
+
+	width, err := integer("width", 256)
+	if err != nil {
+		return nil, err
+	}
+	height, err := integer("height", 256)
+	if err != nil {
+		return nil, err
+	}
+	if width <= 0 || height <= 0 || width*height > maxRenderPixels {
+		return nil, fmt.Errorf("invalid image size %d x %d", width, height)
+	}
+
+	opts := NewRenderOptions(width, height)
+	if opts.DomainWidth, err = float("domainwidth", opts.DomainWidth); err != nil {
+		return nil, err
+	}
+	if opts.DomainHeight, err = float("domainheight", opts.DomainHeight); err != nil {
+		return nil, err
+	}
+	if opts.Zoom, err = float("zoom", opts.Zoom); err != nil {
+		return nil, err
+	}
+	if opts.Zoom <= 0.0 {
+		return nil, errors.New("zoom must be positive")
+	}
+	if opts.PanX, err = float("panx", opts.PanX); err != nil {
+		return nil, err
+	}
+	if opts.PanY, err = float("pany", opts.PanY); err != nil {
+		return nil, err
+	}
+	if opts.Rotation, err = float("rotation", opts.Rotation); err != nil {
+		return nil, err
+	}
+	if opts.Time, err = float("t", opts.Time); err != nil {
+		return nil, err
+	}
+	if v := q.Get("latent"); v != "" {
+		for _, field := range strings.Split(v, ",") {
+			z, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				return nil, err
+			}
+			opts.Latent = append(opts.Latent, z)
+		}
+	}
+	if opts.Samples, err = integer("samples", opts.Samples); err != nil {
+		return nil, err
+	}
+	if opts.Samples > maxRenderSamples {
+		return nil, fmt.Errorf("too many samples %d", opts.Samples)
+	}
+	opts.Jitter = q.Get("jitter") == "true" || q.Get("jitter") == "1"
+	if v := q.Get("filter"); v != "" {
+		opts.Filter = v
+	}
+	if v := q.Get("encoding"); v != "" {
+		opts.Encoding = v
+	}
+	if opts.Symmetry, err = ParseSymmetry(q.Get("symmetry")); err != nil {
+		return nil, err
+	}
+	var palette []string
+	if v := q.Get("palette"); v != "" {
+		palette = strings.Split(v, ",")
+	}
+	if opts.ColorSpace, err = ParseColorSpace(q.Get("colorspace"),
+		palette); err != nil {
+		return nil, err
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	return opts, nil
+}
+
+// ServeHTTP handles uploads of genomes to /genomes, and renders of uploaded
+// genomes from /render/[id].
+func (s *RenderServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/genomes" && r.Method == http.MethodPost:
+		id, err := s.Upload(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintln(w, id)
+
+	case strings.HasPrefix(r.URL.Path, "/render/"):
+		id := strings.TrimPrefix(r.URL.Path, "/render/")
+		g, err := s.find(id)
+		if err == ErrNotFound {
+			http.NotFound(w, r)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.render(w, r, id, g)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// render renders the argument genome, and writes the encoded image in the
+// format of the request ("png" or "jpeg").
+func (s *RenderServer) render(w http.ResponseWriter, r *http.Request,
+	id string, g *Genome) {
+	opts, err := parseRenderOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := opts.ColorSpace.Validate(g.NumOutputs); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "png"
+	}
+	if format != "png" && format != "jpeg" {
+		http.Error(w, "invalid format "+format, http.StatusBadRequest)
+		return
+	}
+
+	entry, err := s.Cache.Get(id, g)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	entry.mu.Lock()
+	img := entry.renderer.Render(opts)
+	entry.mu.Unlock()
+
+	w.Header().Set("Content-Type", "image/"+format)
+	if format == "jpeg" {
+		jpeg.Encode(w, img, nil)
+	} else {
+		png.Encode(w, img)
+	}
+}
+
+// api starts the rendering API, given an optional address to listen on and
+// an optional path of a genome store.
+func api(args []string) error {
+	if len(args) > 2 {
+		return errors.New("usage: imagen api [address] [store]")
+	}
+
+	addr := "localhost:8081"
+	if len(args) >= 1 {
+		addr = args[0]
+	}
+
+	server := NewRenderServer(64, 1024)
+	if len(args) == 2 {
+		store, err := OpenStore(args[1])
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		server.Store = store
+	}
+
+	fmt.Printf("Serving rendering API at http://%s\n", addr)
+	return http.ListenAndServe(addr, server)
+}
@@ -0,0 +1,166 @@
+/*
+
+
+api_test.go tests for the rendering API.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDPPNCache(t *testing.T) {
+	rand.Seed(0)
+
+	genomes := make([]*Genome, 3)
+	for i := range genomes {
+		genomes[i] = NewGenome(i, 4, 2, 3)
+	}
+	c := NewDPPNCache(2)
+	get := func(i int) *compiledDPPN {
+		entry, err := c.Get(string(rune('a'+i)), genomes[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return entry
+	}
+
+	// a hit returns the same compiled DPPN
+	a := get(0)
+	get(1)
+	if get(0) != a {
+		t.Error("cached DPPN is compiled again")
+	}
+
+	// the least recently used DPPN is evicted
+	get(2)
+	if c.order.Len() != 2 || len(c.entries) != 2 {
+		t.Errorf("cache has %d DPPNs, expected 2", c.order.Len())
+	}
+	if _, ok := c.entries["b"]; ok {
+		t.Error("least recently used DPPN is not evicted")
+	}
+	if get(0) != a {
+		t.Error("recently used DPPN is evicted")
+	}
+	if c.order.Front().Value.(*compiledDPPN).key != "a" {
+		t.Error("most recently used DPPN is not first")
+	}
+}
+
+func TestRenderServer(t *testing.T) {
+	rand.Seed(0)
+
+	s := NewRenderServer(2, 2)
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	upload := func(g *Genome) string {
+		var buf bytes.Buffer
+		if err := g.ExportTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.Post(server.URL+"/genomes", "text/plain", &buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var id bytes.Buffer
+		id.ReadFrom(resp.Body)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("upload failed: %s", id.String())
+		}
+		return strings.TrimSpace(id.String())
+	}
+
+	ids := make([]string, 3)
+	for i := range ids {
+		g := NewGenome(i, 4, 2, 3)
+		for j := 0; j < 5; j++ {
+			g.Mutate(0.5, 0.5)
+		}
+		ids[i] = upload(g)
+	}
+
+	// the oldest genome is forgotten
+	if len(s.Genomes) != 2 {
+		t.Errorf("server keeps %d genomes, expected 2", len(s.Genomes))
+	}
+	resp, err := http.Get(server.URL + "/render/" + ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("forgotten genome is rendered with status %d", resp.StatusCode)
+	}
+
+	// a single output is rendered in gray, unless the request's color space
+	// needs more outputs
+	gray := upload(NewGenome(3, 4, 2, 1))
+	palette := "?colorspace=palette&palette=%23000000,%23ffffff"
+	for _, c := range []struct {
+		id, query string
+		status    int
+	}{
+		{ids[2], "?width=8&height=4&encoding=torus&colorspace=hsv",
+			http.StatusOK},
+		{ids[2], palette, http.StatusOK},
+		{ids[2], palette + ",%23ff0000,%2300ff00", http.StatusBadRequest},
+		{ids[2], "?encoding=polar", http.StatusBadRequest},
+		{ids[2], "?colorspace=cmyk", http.StatusBadRequest},
+		{gray, "", http.StatusOK},
+		{gray, palette, http.StatusBadRequest},
+	} {
+		query, status := c.query, c.status
+		resp, err := http.Get(server.URL + "/render/" + c.id + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != status {
+			t.Errorf("render%s has status %d, expected %d", query,
+				resp.StatusCode, status)
+		} else if status == http.StatusOK {
+			if _, err := png.Decode(resp.Body); err != nil {
+				t.Errorf("render%s: %s", query, err)
+			}
+		}
+		resp.Body.Close()
+	}
+}
@@ -0,0 +1,185 @@
+/*
+
+
+camera.go implementation of keyframed camera paths.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Keyframe is the viewport of a frame of an animation, and the time input.
+type Keyframe struct {
+	Frame    int     // frame number
+	Zoom     float64 // zoom about the center of the domain
+	PanX     float64 // horizontal pan in domain coordinates
+	PanY     float64 // vertical pan in domain coordinates
+	Rotation float64 // clockwise rotation about the center in radians
+	Time     float64 // time input, if the genome has one
+}
+
+// CameraPath is a sequence of keyframes, sorted by frame number. The
+// viewport between keyframes is interpolated.
+type CameraPath []Keyframe
+
+// Validate returns an error if the keyframes are not sorted by frame
+// number, or if their zoom is not positive.
+func (p CameraPath) Validate() error {
+	for i, k := range p {
+		if k.Zoom <= 0.0 {
+			return fmt.Errorf("keyframe %d has invalid zoom %f", k.Frame, k.Zoom)
+		}
+		if i > 0 && k.Frame <= p[i-1].Frame {
+			return fmt.Errorf("keyframe %d is not after keyframe %d",
+				k.Frame, p[i-1].Frame)
+		}
+	}
+	return nil
+}
+
+// NumFrames returns the number of frames of the path, up to its last
+// keyframe.
+func (p CameraPath) NumFrames() int {
+	if len(p) == 0 {
+		return 0
+	}
+	return p[len(p)-1].Frame + 1
+}
+
+// At returns the keyframe of the argument frame, interpolated between the
+// keyframes around it. The pan, rotation and time are interpolated linearly,
+// and the zoom geometrically, so that zooming in looks steady. Frames before
+// the first and after the last keyframes hold them.
+func (p CameraPath) At(frame int) Keyframe {
+	i := sort.Search(len(p), func(i int) bool {
+		return p[i].Frame >= frame
+	})
+	switch {
+	case i == len(p):
+		k := p[len(p)-1]
+		k.Frame = frame
+		return k
+	case i == 0 || p[i].Frame == frame:
+		k := p[i]
+		k.Frame = frame
+		return k
+	}
+
+	k0, k1 := p[i-1], p[i]
+	t := float64(frame-k0.Frame) / float64(k1.Frame-k0.Frame)
+	lerp := func(a, b float64) float64 {
+		return a + t*(b-a)
+	}
+	return Keyframe{
+		Frame:    frame,
+		Zoom:     math.Exp(lerp(math.Log(k0.Zoom), math.Log(k1.Zoom))),
+		PanX:     lerp(k0.PanX, k1.PanX),
+		PanY:     lerp(k0.PanY, k1.PanY),
+		Rotation: lerp(k0.Rotation, k1.Rotation),
+		Time:     lerp(k0.Time, k1.Time),
+	}
+}
+
+// Apply returns a copy of the argument render options with the viewport and
+// the time of the argument frame.
+func (p CameraPath) Apply(opts *RenderOptions, frame int) *RenderOptions {
+	k := p.At(frame)
+	frameOpts := *opts
+	frameOpts.Zoom = k.Zoom
+	frameOpts.PanX, frameOpts.PanY = k.PanX, k.PanY
+	frameOpts.Rotation = k.Rotation
+	frameOpts.Time = k.Time
+	return &frameOpts
+}
+
+// animate renders an exported genome along the camera path in the argument
+// configuration, as PNG images frame_[frame].png, on the coordinate domain it
+// was trained on. The input encoding and symmetry are the configuration's, or
+// else the genome's.
+func animate(args []string) error {
+	if len(args) != 4 {
+		return errors.New("usage: imagen animate [genome] [width] " +
+			"[height] [config].json")
+	}
+
+	g, meta, err := OpenGenome(args[0])
+	if err != nil {
+		return err
+	}
+	width, err := strconv.Atoi(args[1])
+	if err != nil {
+		return err
+	}
+	height, err := strconv.Atoi(args[2])
+	if err != nil {
+		return err
+	}
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("invalid image size %d x %d", width, height)
+	}
+	config, err := NewConfiguration(args[3])
+	if err != nil {
+		return err
+	}
+	if len(config.RenderPath) == 0 {
+		return errors.New("configuration has no camera path (RenderPath)")
+	}
+	config.inherit(meta)
+	opts, err := config.FinalRenderOptions(width, height)
+	if err != nil {
+		return err
+	}
+	if err := opts.ColorSpace.Validate(g.NumOutputs); err != nil {
+		return err
+	}
+	opts.DomainWidth, opts.DomainHeight = trainingDomain(meta, width, height)
+
+	r, err := NewRenderer(g, 0)
+	if err != nil {
+		return err
+	}
+	numFrames := config.RenderPath.NumFrames()
+	for frame := 0; frame < numFrames; frame++ {
+		img := r.Render(config.RenderPath.Apply(opts, frame))
+		if err := writePNG(fmt.Sprintf("frame_%05d.png", frame), img); err != nil {
+			return err
+		}
+		fmt.Printf("Frame [%5d / %5d]\n", frame+1, numFrames)
+	}
+	return nil
+}
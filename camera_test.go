@@ -0,0 +1,130 @@
+/*
+
+
+camera_test.go tests for keyframed camera paths.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestCameraPath(t *testing.T) {
+	path := CameraPath{
+		{Frame: 10, Zoom: 1.0, PanX: 0.0, Rotation: 0.0},
+		{Frame: 20, Zoom: 4.0, PanX: 10.0, Rotation: math.Pi},
+	}
+	if err := path.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if n := path.NumFrames(); n != 21 {
+		t.Errorf("path has %d frames, expected 21", n)
+	}
+
+	if k := path.At(0); k.Zoom != 1.0 || k.PanX != 0.0 {
+		t.Errorf("frame before the path is %+v", k)
+	}
+	if k := path.At(30); k.Zoom != 4.0 || k.PanX != 10.0 {
+		t.Errorf("frame after the path is %+v", k)
+	}
+	k := path.At(15)
+	if math.Abs(k.Zoom-2.0) > 1e-12 || math.Abs(k.PanX-5.0) > 1e-12 ||
+		math.Abs(k.Rotation-math.Pi/2.0) > 1e-12 {
+		t.Errorf("frame in the middle of the path is %+v", k)
+	}
+
+	invalid := []CameraPath{
+		{{Frame: 0, Zoom: 0.0}},
+		{{Frame: 5, Zoom: 1.0}, {Frame: 5, Zoom: 1.0}},
+	}
+	for _, p := range invalid {
+		if err := p.Validate(); err == nil {
+			t.Errorf("path %+v is valid", p)
+		}
+	}
+}
+
+func TestRenderViewport(t *testing.T) {
+	// rotating clockwise by a quarter turn shows the point above the center
+	// to the right of it
+	opts := NewRenderOptions(10, 10)
+	opts.Rotation = math.Pi / 2.0
+	x, y := opts.Coordinates(6.0, 5.0)
+	if math.Abs(x-5.0) > 1e-12 || math.Abs(y-4.0) > 1e-12 {
+		t.Errorf("rotated coordinate is (%f, %f), expected (5, 4)", x, y)
+	}
+
+	opts.Zoom, opts.PanX = 2.0, 1.0
+	x, y = opts.Coordinates(6.0, 5.0)
+	if math.Abs(x-6.0) > 1e-12 || math.Abs(y-4.5) > 1e-12 {
+		t.Errorf("transformed coordinate is (%f, %f), expected (6, 4.5)", x, y)
+	}
+}
+
+func TestRenderWarp(t *testing.T) {
+	rand.Seed(0)
+
+	g := NewGenome(0, 4, 4, 3)
+	warp := NewGenome(1, 4, 2, 2)
+	for i := 0; i < 10; i++ {
+		g.Mutate(0.5, 0.5)
+		warp.Mutate(0.5, 0.5)
+	}
+	r, err := NewRenderer(g, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := NewRenderOptions(16, 16)
+	img := r.Render(opts)
+
+	opts.Warp, opts.WarpStrength = warp, 0.0
+	if err := opts.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(r.Render(opts).Pix, img.Pix) {
+		t.Error("warp of zero strength changes the render")
+	}
+	opts.WarpStrength = 5.0
+	if bytes.Equal(r.Render(opts).Pix, img.Pix) {
+		t.Error("warp does not change the render")
+	}
+
+	opts.Warp = NewGenome(2, 4, 2, 1)
+	if err := opts.Validate(); err == nil {
+		t.Error("warp genome with 1 output is valid")
+	}
+}
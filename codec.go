@@ -0,0 +1,504 @@
+/*
+
+
+codec.go implementation of versioned JSON and binary encodings of genomes.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// GenomeFormatVersion is the version of the JSON and binary encodings of
+// genomes written by this program. Decoders accept any version up to it.
+const GenomeFormatVersion = 1
+
+// binaryMagic starts every binary encoding of a genome.
+var binaryMagic = []byte("IMGN")
+
+// GenomeMeta contains the metadata stored along with an encoded genome.
+type GenomeMeta struct {
+	ID            int            `json:"id"`            // genome ID
+	Fitness       jsonFloat      `json:"fitness"`       // fitness score
+	InputEncoding string         `json:"inputEncoding"` // coordinate encoding
+	ColorSpace    string         `json:"colorSpace"`    // output color space
+	Config        *Configuration `json:"config"`        // training config
+	Parents       []string       `json:"parents"`       // parent genome IDs
+}
+
+// NewGenomeMeta creates metadata of the argument genome trained with the
+// argument configuration, which may be nil.
+func NewGenomeMeta(g *Genome, config *Configuration) *GenomeMeta {
+	meta := &GenomeMeta{
+		ID:            g.ID,
+		Fitness:       jsonFloat(g.Fitness),
+		InputEncoding: "cartesian",
+		ColorSpace:    "rgb",
+		Config:        config,
+		Parents:       make([]string, 0),
+	}
+	if config != nil && config.InputEncoding != "" {
+		meta.InputEncoding = config.InputEncoding
+	}
+	if config != nil && config.ColorSpace != "" {
+		meta.ColorSpace = config.ColorSpace
+	}
+	return meta
+}
+
+// jsonFloat is a float64 that is encoded in JSON as a string if it is not a
+// finite number, since JSON numbers cannot be NaN or infinite.
+type jsonFloat float64
+
+func (f jsonFloat) MarshalJSON() ([]byte, error) {
+	v := float64(f)
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return json.Marshal(strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	return json.Marshal(v)
+}
+
+func (f *jsonFloat) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		v, err := strconv.ParseFloat(s, 64)
+		*f = jsonFloat(v)
+		return err
+	}
+	var v float64
+	err := json.Unmarshal(data, &v)
+	*f = jsonFloat(v)
+	return err
+}
+
+// genomeJSON is the JSON encoding of a genome.
+type genomeJSON struct {
+	Version    int        `json:"version"`
+	Meta       GenomeMeta `json:"meta"`
+	NumInputs  int        `json:"numInputs"`
+	NumOutputs int        `json:"numOutputs"`
+	NumHidden  int        `json:"numHidden"`
+	Nodes      []nodeJSON `json:"nodes"`
+	Edges      []edgeJSON `json:"edges"`
+}
+
+type nodeJSON struct {
+	ID         int    `json:"id"`
+	Type       string `json:"type"`
+	Activation string `json:"activation"`
+}
+
+type edgeJSON struct {
+	From   int       `json:"from"`
+	To     int       `json:"to"`
+	Weight jsonFloat `json:"weight"`
+}
+
+// assemble creates a genome from decoded counts, nodes and edges, resolving
+// each edge's nodes by ID.
+func assemble(meta *GenomeMeta, numInputs, numOutputs, numHidden int,
+	nodes []nodeJSON, edges []edgeJSON) (*Genome, error) {
+	g := &Genome{
+		ID:         meta.ID,
+		NumInputs:  numInputs,
+		NumOutputs: numOutputs,
+		NumHidden:  numHidden,
+		NodeGenes:  make([]*NodeGene, len(nodes)),
+		EdgeGenes:  make([]*EdgeGene, len(edges)),
+		Fitness:    float64(meta.Fitness),
+	}
+
+	byID := make(map[int]*NodeGene)
+	for i, n := range nodes {
+		if _, ok := byID[n.ID]; ok {
+			return nil, fmt.Errorf("duplicate node %d", n.ID)
+		}
+		g.NodeGenes[i] = NewNodeGene(n.ID, n.Type, n.Activation)
+		byID[n.ID] = g.NodeGenes[i]
+	}
+	for i, e := range edges {
+		if byID[e.From] == nil || byID[e.To] == nil {
+			return nil, fmt.Errorf("edge between unknown nodes %d and %d",
+				e.From, e.To)
+		}
+		g.EdgeGenes[i] = &EdgeGene{
+			InputNode:  byID[e.From],
+			OutputNode: byID[e.To],
+			Weight:     float64(e.Weight),
+		}
+	}
+
+	return g, nil
+}
+
+// EncodeGenomeJSON writes the argument genome and its metadata as JSON.
+func EncodeGenomeJSON(w io.Writer, g *Genome, meta *GenomeMeta) error {
+	data := genomeJSON{
+		Version:    GenomeFormatVersion,
+		Meta:       *meta,
+		NumInputs:  g.NumInputs,
+		NumOutputs: g.NumOutputs,
+		NumHidden:  g.NumHidden,
+		Nodes:      make([]nodeJSON, len(g.NodeGenes)),
+		Edges:      make([]edgeJSON, len(g.EdgeGenes)),
+	}
+	for i, node := range g.NodeGenes {
+		data.Nodes[i] = nodeJSON{node.ID, node.Type, node.AFuncType}
+	}
+	for i, edge := range g.EdgeGenes {
+		data.Edges[i] = edgeJSON{edge.InputNode.ID, edge.OutputNode.ID,
+			jsonFloat(edge.Weight)}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(&data)
+}
+
+// DecodeGenomeJSON reads a genome and its metadata encoded as JSON.
+func DecodeGenomeJSON(r io.Reader) (*Genome, *GenomeMeta, error) {
+	var data genomeJSON
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return nil, nil, err
+	}
+	if data.Version < 1 || data.Version > GenomeFormatVersion {
+		return nil, nil, fmt.Errorf("unsupported genome format version %d",
+			data.Version)
+	}
+
+	g, err := assemble(&data.Meta, data.NumInputs, data.NumOutputs,
+		data.NumHidden, data.Nodes, data.Edges)
+	if err != nil {
+		return nil, nil, err
+	}
+	return g, &data.Meta, nil
+}
+
+// binaryWriter writes the fields of the binary encoding, keeping the first
+// error.
+type binaryWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (b *binaryWriter) write(p []byte) {
+	if b.err == nil {
+		_, b.err = b.w.Write(p)
+	}
+}
+
+func (b *binaryWriter) uvarint(v uint64) {
+	buf := make([]byte, binary.MaxVarintLen64)
+	b.write(buf[:binary.PutUvarint(buf, v)])
+}
+
+func (b *binaryWriter) varint(v int64) {
+	buf := make([]byte, binary.MaxVarintLen64)
+	b.write(buf[:binary.PutVarint(buf, v)])
+}
+
+func (b *binaryWriter) float(v float64) {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, math.Float64bits(v))
+	b.write(buf)
+}
+
+func (b *binaryWriter) bytes(p []byte) {
+	b.uvarint(uint64(len(p)))
+	b.write(p)
+}
+
+// binaryReader reads the fields of the binary encoding, keeping the first
+// error.
+type binaryReader struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (b *binaryReader) uvarint() uint64 {
+	if b.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(b.r)
+	b.err = err
+	return v
+}
+
+func (b *binaryReader) varint() int64 {
+	if b.err != nil {
+		return 0
+	}
+	v, err := binary.ReadVarint(b.r)
+	b.err = err
+	return v
+}
+
+func (b *binaryReader) float() float64 {
+	buf := make([]byte, 8)
+	if b.err == nil {
+		_, b.err = io.ReadFull(b.r, buf)
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(buf))
+}
+
+func (b *binaryReader) bytes() []byte {
+	n := b.uvarint()
+	if b.err != nil {
+		return nil
+	}
+	if n > 1<<26 {
+		b.err = errors.New("invalid length in binary genome")
+		return nil
+	}
+	// the buffer grows as the bytes are read, so that a corrupt length
+	// cannot cause a huge allocation
+	buf, err := io.ReadAll(io.LimitReader(b.r, int64(n)))
+	if b.err = err; err == nil && uint64(len(buf)) < n {
+		b.err = io.ErrUnexpectedEOF
+	}
+	return buf
+}
+
+// count reads a number of elements, rejecting counts beyond the argument
+// limit. Slices of elements are grown as the elements are read, so that a
+// corrupt count cannot cause a huge allocation.
+func (b *binaryReader) count(limit int) int {
+	n := b.uvarint()
+	if b.err == nil && n > uint64(limit) {
+		b.err = errors.New("invalid count in binary genome")
+		return 0
+	}
+	return int(n)
+}
+
+// EncodeGenomeBinary writes the argument genome and its metadata in a
+// compact binary encoding: a magic number and the version, followed by the
+// metadata, the counts, the nodes and the edges. Integers are encoded as
+// varints, strings are prefixed by their lengths, and weights are encoded as
+// little-endian float64s, as in Protocol Buffers.
+func EncodeGenomeBinary(w io.Writer, g *Genome, meta *GenomeMeta) error {
+	config, err := json.Marshal(meta.Config)
+	if err != nil {
+		return err
+	}
+
+	b := &binaryWriter{w: bufio.NewWriter(w)}
+	b.write(binaryMagic)
+	b.uvarint(GenomeFormatVersion)
+
+	// metadata
+	b.varint(int64(meta.ID))
+	b.float(float64(meta.Fitness))
+	b.bytes([]byte(meta.InputEncoding))
+	b.bytes([]byte(meta.ColorSpace))
+	b.bytes(config)
+	b.uvarint(uint64(len(meta.Parents)))
+	for _, parent := range meta.Parents {
+		b.bytes([]byte(parent))
+	}
+
+	// genome
+	b.varint(int64(g.NumInputs))
+	b.varint(int64(g.NumOutputs))
+	b.varint(int64(g.NumHidden))
+	b.uvarint(uint64(len(g.NodeGenes)))
+	for _, node := range g.NodeGenes {
+		b.varint(int64(node.ID))
+		b.bytes([]byte(node.Type))
+		b.bytes([]byte(node.AFuncType))
+	}
+	b.uvarint(uint64(len(g.EdgeGenes)))
+	for _, edge := range g.EdgeGenes {
+		b.varint(int64(edge.InputNode.ID))
+		b.varint(int64(edge.OutputNode.ID))
+		b.float(edge.Weight)
+	}
+
+	if b.err != nil {
+		return b.err
+	}
+	return b.w.Flush()
+}
+
+// DecodeGenomeBinary reads a genome and its metadata in the binary encoding.
+func DecodeGenomeBinary(r io.Reader) (*Genome, *GenomeMeta, error) {
+	b := &binaryReader{r: bufio.NewReader(r)}
+
+	magic := make([]byte, len(binaryMagic))
+	if _, err := io.ReadFull(b.r, magic); err != nil {
+		return nil, nil, err
+	}
+	if !bytes.Equal(magic, binaryMagic) {
+		return nil, nil, errors.New("not a binary genome")
+	}
+	if version := b.uvarint(); b.err == nil &&
+		(version < 1 || version > GenomeFormatVersion) {
+		return nil, nil, fmt.Errorf("unsupported genome format version %d",
+			version)
+	}
+
+	// metadata
+	meta := &GenomeMeta{Parents: make([]string, 0)}
+	meta.ID = int(b.varint())
+	meta.Fitness = jsonFloat(b.float())
+	meta.InputEncoding = string(b.bytes())
+	meta.ColorSpace = string(b.bytes())
+	config := b.bytes()
+	numParents := b.count(1 << 20)
+	for i := 0; i < numParents && b.err == nil; i++ {
+		meta.Parents = append(meta.Parents, string(b.bytes()))
+	}
+	if b.err == nil {
+		if err := json.Unmarshal(config, &meta.Config); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// genome
+	numInputs := int(b.varint())
+	numOutputs := int(b.varint())
+	numHidden := int(b.varint())
+	nodes := make([]nodeJSON, 0)
+	numNodes := b.count(1 << 24)
+	for i := 0; i < numNodes && b.err == nil; i++ {
+		n := nodeJSON{ID: int(b.varint())}
+		n.Type = string(b.bytes())
+		n.Activation = string(b.bytes())
+		nodes = append(nodes, n)
+	}
+	edges := make([]edgeJSON, 0)
+	numEdges := b.count(1 << 26)
+	for i := 0; i < numEdges && b.err == nil; i++ {
+		e := edgeJSON{From: int(b.varint())}
+		e.To = int(b.varint())
+		e.Weight = jsonFloat(b.float())
+		edges = append(edges, e)
+	}
+	if b.err != nil {
+		return nil, nil, b.err
+	}
+
+	g, err := assemble(meta, numInputs, numOutputs, numHidden, nodes, edges)
+	if err != nil {
+		return nil, nil, err
+	}
+	return g, meta, nil
+}
+
+// ReadGenome reads a genome in any of its encodings: binary, JSON, or the
+// legacy text format written by Export, which has no metadata besides
+// default values.
+func ReadGenome(r io.Reader) (*Genome, *GenomeMeta, error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(len(binaryMagic))
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+
+	switch {
+	case bytes.Equal(head, binaryMagic):
+		return DecodeGenomeBinary(br)
+	case len(bytes.TrimSpace(head)) > 0 && bytes.TrimSpace(head)[0] == '{':
+		return DecodeGenomeJSON(br)
+	}
+
+	g, err := ImportGenome(br, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	return g, NewGenomeMeta(g, nil), nil
+}
+
+// OpenGenome reads a genome from a file in any of its encodings, as in
+// ReadGenome.
+func OpenGenome(filename string) (*Genome, *GenomeMeta, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	return ReadGenome(f)
+}
+
+// WriteGenome writes the argument genome and its metadata to a file, in the
+// encoding given by the file's extension: ".json" for JSON, ".bin" for
+// binary, and the legacy text format otherwise.
+func WriteGenome(filename string, g *Genome, meta *GenomeMeta) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	switch filepath.Ext(filename) {
+	case ".json":
+		return EncodeGenomeJSON(f, g, meta)
+	case ".bin":
+		return EncodeGenomeBinary(f, g, meta)
+	default:
+		return g.ExportTo(f)
+	}
+}
+
+// ExportGenome writes the argument genome with the metadata of the argument
+// training configuration to genome_[id]_[exported time].json, so that it is
+// rendered as trained, and returns the file's name.
+func ExportGenome(g *Genome, config *Configuration) (string, error) {
+	filename := fmt.Sprintf("genome_%d_%d.json", g.ID, time.Now().UnixNano())
+	return filename, WriteGenome(filename, g, NewGenomeMeta(g, config))
+}
+
+// convert converts a genome from one encoding to another.
+func convert(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: imagen convert [input] [output]" +
+			"(.json|.bin|.txt)")
+	}
+
+	g, meta, err := OpenGenome(args[0])
+	if err != nil {
+		return err
+	}
+	return WriteGenome(args[1], g, meta)
+}
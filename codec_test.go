@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"math"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// testGenome returns a mutated genome with a NaN and an infinite weight,
+// which a JSON number cannot hold.
+func testGenome() *Genome {
+	g := NewGenome(7, 4, 3, 3)
+	for i := 0; i < 10; i++ {
+		g.Mutate(0.5, 0.5)
+	}
+	g.EdgeGenes[0].Weight = math.NaN()
+	g.EdgeGenes[1].Weight = math.Inf(-1)
+	g.Fitness = 0.25
+	return g
+}
+
+func TestGenomeCodecs(t *testing.T) {
+	rand.Seed(0)
+
+	g := testGenome()
+	meta := NewGenomeMeta(g, &Configuration{Seed: 1, NumInputs: 4})
+	meta.Parents = []string{"a", "b"}
+
+	encoders := map[string]func(*bytes.Buffer) error{
+		"json": func(b *bytes.Buffer) error {
+			return EncodeGenomeJSON(b, g, meta)
+		},
+		"binary": func(b *bytes.Buffer) error {
+			return EncodeGenomeBinary(b, g, meta)
+		},
+	}
+	for name, encode := range encoders {
+		var buf bytes.Buffer
+		if err := encode(&buf); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		decoded, decodedMeta, err := ReadGenome(&buf)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if !decoded.Equal(g) || decoded.ID != g.ID ||
+			decoded.Fitness != g.Fitness {
+			t.Errorf("%s: decoded genome differs from the original", name)
+		}
+		if !reflect.DeepEqual(decodedMeta, meta) {
+			t.Errorf("%s: decoded metadata %+v differs from %+v",
+				name, decodedMeta, meta)
+		}
+	}
+}
+
+func TestGenomeCodecLegacy(t *testing.T) {
+	rand.Seed(0)
+
+	g := NewGenome(0, 4, 3, 3)
+	var buf bytes.Buffer
+	if err := g.ExportTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	legacy := buf.String()
+
+	decoded, _, err := ReadGenome(strings.NewReader(legacy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.NumInputs != 4 || decoded.NumOutputs != 3 ||
+		decoded.NumHidden != 3 || len(decoded.EdgeGenes) != len(g.EdgeGenes) {
+		t.Error("legacy genome decoded with the wrong structure")
+	}
+
+	// the legacy format only keeps 6 decimals of each weight
+	var reencoded bytes.Buffer
+	decoded.ExportTo(&reencoded)
+	if reencoded.String() != legacy {
+		t.Error("legacy genome changed after a round trip")
+	}
+}
+
+func TestGenomeCodecVersion(t *testing.T) {
+	rand.Seed(0)
+
+	g := testGenome()
+	var buf bytes.Buffer
+	EncodeGenomeBinary(&buf, g, NewGenomeMeta(g, nil))
+	data := buf.Bytes()
+	data[len(binaryMagic)] = GenomeFormatVersion + 1
+	if _, _, err := ReadGenome(bytes.NewReader(data)); err == nil {
+		t.Error("expected an error for a newer binary format version")
+	}
+
+	json := `{"version": 99, "nodes": [], "edges": []}`
+	if _, _, err := ReadGenome(strings.NewReader(json)); err == nil {
+		t.Error("expected an error for a newer JSON format version")
+	}
+}
+
+func TestGenomeBinaryCorrupt(t *testing.T) {
+	// header writes the magic number, the version and the metadata of a
+	// binary genome, and the argument numbers of inputs and outputs
+	header := func(b *binaryWriter) {
+		b.write(binaryMagic)
+		b.uvarint(GenomeFormatVersion)
+		b.varint(0)
+		b.float(0.0)
+		b.bytes([]byte("xy"))
+		b.bytes([]byte("rgb"))
+		b.bytes([]byte("{}"))
+		b.uvarint(0)
+		b.varint(2)
+		b.varint(3)
+		b.varint(0)
+	}
+
+	// truncated data with the maximum counts and lengths are errors, and
+	// are not allocated for
+	for _, corrupt := range []func(b *binaryWriter){
+		func(b *binaryWriter) {
+			header(b)
+			b.uvarint(1 << 24)
+		},
+		func(b *binaryWriter) {
+			header(b)
+			b.uvarint(0)
+			b.uvarint(1 << 26)
+		},
+		func(b *binaryWriter) {
+			header(b)
+			b.uvarint(1)
+			b.varint(0)
+			b.uvarint(1 << 26)
+		},
+	} {
+		var buf bytes.Buffer
+		b := &binaryWriter{w: bufio.NewWriter(&buf)}
+		corrupt(b)
+		b.w.Flush()
+
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		_, _, err := DecodeGenomeBinary(bytes.NewReader(buf.Bytes()))
+		runtime.ReadMemStats(&after)
+		if err == nil {
+			t.Error("expected an error for a truncated binary genome")
+		}
+		if after.TotalAlloc-before.TotalAlloc > 1<<20 {
+			t.Errorf("decoding a truncated genome allocated %d bytes",
+				after.TotalAlloc-before.TotalAlloc)
+		}
+	}
+}
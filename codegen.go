@@ -0,0 +1,757 @@
+/*
+
+
+codegen.go implementation of source code generation from genomes.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// codeTerm is a weighted input of a node in generated code.
+type codeTerm struct {
+	Weight float64 // connection weight
+	Input  int     // ID of the input node
+}
+
+// codeNode is a node of the DPPN in generated code, in evaluation order.
+type codeNode struct {
+	ID    int        // node ID
+	Type  string     // node type
+	AFunc string     // name of activation function
+	Terms []codeTerm // weighted inputs, sorted by input node ID
+}
+
+// program is the forward pass of a DPPN flattened into a sequence of nodes,
+// from which source code in each language is generated.
+type program struct {
+	ID      int        // genome ID
+	Inputs  []int      // IDs of input nodes, in input order
+	Outputs []int      // IDs of output nodes, in output order
+	Nodes   []codeNode // non-input nodes, in topological order
+	AFuncs  []string   // names of the activation functions in use
+}
+
+// newProgram compiles the argument genome into a program that computes the
+// same function as its DPPN's forward pass.
+func newProgram(g *Genome) (*program, error) {
+	d, err := NewDPPN(g, 1)
+	if err != nil {
+		return nil, err
+	}
+	return d.program(), nil
+}
+
+// program flattens the forward pass of this DPPN into a program.
+func (d *DPPN) program() *program {
+	p := &program{ID: d.ID}
+	for i := 0; i < d.NumInputs; i++ {
+		p.Inputs = append(p.Inputs, d.Nodes[i].ID)
+	}
+	for i := 0; i < d.NumOutputs; i++ {
+		p.Outputs = append(p.Outputs, d.Nodes[d.NumInputs+i].ID)
+	}
+
+	// order the nodes so that each node comes after all of its inputs
+	isInput := make(map[*Node]bool)
+	for i := 0; i < d.NumInputs; i++ {
+		isInput[d.Nodes[i]] = true
+	}
+	visited := make(map[*Node]bool)
+	afuncs := make(map[string]bool)
+	var visit func(n *Node)
+	visit = func(n *Node) {
+		if visited[n] || isInput[n] {
+			return
+		}
+		visited[n] = true
+
+		terms := make([]codeTerm, 0, len(n.Inputs))
+		for input, weight := range n.Inputs {
+			visit(input)
+			terms = append(terms, codeTerm{weight, input.ID})
+		}
+		sort.Slice(terms, func(i, j int) bool {
+			return terms[i].Input < terms[j].Input
+		})
+
+		if len(terms) > 0 {
+			afuncs[n.AFunc.Name] = true
+		}
+		p.Nodes = append(p.Nodes, codeNode{n.ID, n.Type, n.AFunc.Name, terms})
+	}
+	for _, n := range d.Nodes {
+		visit(n)
+	}
+
+	for name := range afuncs {
+		p.AFuncs = append(p.AFuncs, name)
+	}
+	sort.Strings(p.AFuncs)
+
+	return p
+}
+
+// codeLanguage defines how a program is written in a target language.
+type codeLanguage struct {
+	float   func(float64) string             // float literal
+	afuncs  map[string]string                // activation function helpers
+	apply   func(afunc, x string) string     // activation function call
+	assign  func(id int, expr string) string // assignment to a node variable
+	declare func(name, expr string) string   // declaration of a variable
+	set     func(name, expr string) string   // assignment to a variable
+	math    func(fn string) string           // name of a math function
+	colors  map[string]string                // color space conversions to RGB
+}
+
+// floatLiteral formats a float64 exactly, with the argument spellings of
+// NaN and infinities, and a decimal point if it is an integer.
+func floatLiteral(v float64, nan, inf string) string {
+	switch {
+	case math.IsNaN(v):
+		return nan
+	case math.IsInf(v, 1):
+		return inf
+	case math.IsInf(v, -1):
+		return "(-" + inf + ")"
+	}
+	s := strconv.FormatFloat(v, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eE") {
+		s += ".0"
+	}
+	if v < 0.0 {
+		s = "(" + s + ")"
+	}
+	return s
+}
+
+// body writes the assignments of the program's node variables in the
+// argument language, indented by the argument prefix.
+func (p *program) body(lang *codeLanguage, indent string) string {
+	var b strings.Builder
+	for _, n := range p.Nodes {
+		// as in the DPPN, a node without inputs has a signal of zero
+		expr := lang.float(0.0)
+		if len(n.Terms) > 0 {
+			terms := make([]string, len(n.Terms))
+			for i, t := range n.Terms {
+				terms[i] = fmt.Sprintf("%s*v%d", lang.float(t.Weight), t.Input)
+			}
+			expr = lang.apply(n.AFunc, strings.Join(terms, " + "))
+		}
+		b.WriteString(indent + lang.assign(n.ID, expr) + "\n")
+	}
+	return b.String()
+}
+
+// helpers writes the activation function helpers used by the program.
+func (p *program) helpers(lang *codeLanguage) string {
+	var b strings.Builder
+	for _, name := range p.AFuncs {
+		if helper, ok := lang.afuncs[name]; ok {
+			b.WriteString(helper + "\n")
+		}
+	}
+	return b.String()
+}
+
+// codeColors returns the number of color outputs of a program with the
+// argument number of outputs, and whether an alpha output follows them, as in
+// ColorSpace.Channels. It returns an error if generated code cannot convert
+// the color space to RGB; only rgb, hsv and hsl are converted.
+func codeColors(cs ColorSpace, numOutputs int) (int, bool, error) {
+	switch cs.Name {
+	case "", "rgb", "hsv", "hsl":
+	default:
+		return 0, false, fmt.Errorf("%s outputs are not supported in "+
+			"generated code", cs.Name)
+	}
+	numColors, alpha, _ := cs.Channels(numOutputs)
+	return numColors, alpha, nil
+}
+
+// colorHelpers writes the helpers that convert the color space to RGB, if
+// any, given the number of color outputs.
+func (lang *codeLanguage) colorHelpers(cs ColorSpace, numColors int) string {
+	if numColors < 3 || lang.colors[cs.Name] == "" {
+		return ""
+	}
+	return lang.colors["hue"] + "\n" + lang.colors[cs.Name] + "\n"
+}
+
+// The activation functions below match aFuncSet, including the parameters
+// of elu (a = 1) and gaussian (mu = 0, sigma = 1), and the color space
+// conversions match ColorSpace.
+
+var glslLanguage = &codeLanguage{
+	float: func(v float64) string {
+		return floatLiteral(v, "(0.0 / 0.0)", "(1.0 / 0.0)")
+	},
+	afuncs: map[string]string{
+		"sigmoid": "float act_sigmoid(float x) {\n" +
+			"\tx = clamp(5.0 * x, -60.0, 60.0);\n" +
+			"\treturn 1.0 / (1.0 + exp(-x));\n}\n",
+		// tanh saturates in single precision long before the clamp in
+		// aFuncSet, and exp would overflow beyond it.
+		"tanh": "float act_tanh(float x) {\n" +
+			"\tx = clamp(2.5 * x, -15.0, 15.0);\n" +
+			"\tfloat e = exp(2.0 * x);\n" +
+			"\treturn (e - 1.0) / (e + 1.0);\n}\n",
+		"relu": "float act_relu(float x) {\n" +
+			"\treturn x >= 0.0 ? x : 1.0;\n}\n",
+		"elu": "float act_elu(float x) {\n" +
+			"\treturn x >= 0.0 ? x : exp(clamp(2.5 * x, -60.0, 60.0)) - 1.0;\n}\n",
+		"gaussian": "float act_gaussian(float x) {\n" +
+			"\tx = clamp(x, -3.4, 3.4);\n" +
+			"\treturn 0.3989422804014327 * exp(-0.5 * x * x);\n}\n",
+	},
+	apply: func(afunc, x string) string {
+		switch afunc {
+		case "identity":
+			return x
+		case "abs":
+			return "abs(" + x + ")"
+		case "sine":
+			return "sin(" + x + ")"
+		}
+		return "act_" + afunc + "(" + x + ")"
+	},
+	assign: func(id int, expr string) string {
+		return fmt.Sprintf("float v%d = %s;", id, expr)
+	},
+	declare: func(name, expr string) string {
+		return fmt.Sprintf("float %s = %s;", name, expr)
+	},
+	set: func(name, expr string) string {
+		return fmt.Sprintf("%s = %s;", name, expr)
+	},
+	math: func(fn string) string {
+		if fn == "atan2" {
+			return "atan"
+		}
+		return fn
+	},
+	colors: map[string]string{
+		"hue": "vec3 hue_rgb(float h, float chroma, float m) {\n" +
+			"\th = 6.0 * (h - floor(h));\n" +
+			"\tfloat x = chroma * (1.0 - abs(mod(h, 2.0) - 1.0));\n" +
+			"\tvec3 c = vec3(chroma, 0.0, x);\n" +
+			"\tif (h < 1.0) {\n\t\tc = vec3(chroma, x, 0.0);\n" +
+			"\t} else if (h < 2.0) {\n\t\tc = vec3(x, chroma, 0.0);\n" +
+			"\t} else if (h < 3.0) {\n\t\tc = vec3(0.0, chroma, x);\n" +
+			"\t} else if (h < 4.0) {\n\t\tc = vec3(0.0, x, chroma);\n" +
+			"\t} else if (h < 5.0) {\n\t\tc = vec3(x, 0.0, chroma);\n" +
+			"\t}\n\treturn c + m;\n}\n",
+		"hsv": "vec3 hsv_rgb(float h, float s, float v) {\n" +
+			"\tfloat chroma = v * s;\n" +
+			"\treturn hue_rgb(h, chroma, v - chroma);\n}\n",
+		"hsl": "vec3 hsl_rgb(float h, float s, float l) {\n" +
+			"\tfloat chroma = (1.0 - abs(2.0 * l - 1.0)) * s;\n" +
+			"\treturn hue_rgb(h, chroma, l - chroma / 2.0);\n}\n",
+	},
+}
+
+var jsLanguage = &codeLanguage{
+	float: func(v float64) string {
+		return floatLiteral(v, "NaN", "Infinity")
+	},
+	afuncs: map[string]string{
+		"sigmoid": "\tfunction sigmoid(x) {\n" +
+			"\t\tx = Math.max(-60.0, Math.min(60.0, 5.0 * x));\n" +
+			"\t\treturn 1.0 / (1.0 + Math.exp(-x));\n\t}\n",
+		"tanh": "\tfunction tanh(x) {\n" +
+			"\t\treturn Math.tanh(Math.max(-60.0, Math.min(60.0, 2.5 * x)));\n\t}\n",
+		"relu": "\tfunction relu(x) {\n" +
+			"\t\treturn x >= 0.0 ? x : 1.0;\n\t}\n",
+		"elu": "\tfunction elu(x) {\n" +
+			"\t\tif (x >= 0.0) {\n\t\t\treturn x;\n\t\t}\n" +
+			"\t\treturn Math.exp(Math.max(-60.0, Math.min(60.0, 2.5 * x))) - 1.0;\n\t}\n",
+		"gaussian": "\tfunction gaussian(x) {\n" +
+			"\t\tx = Math.max(-3.4, Math.min(3.4, x));\n" +
+			"\t\treturn (1.0 / Math.sqrt(2.0 * Math.PI)) * Math.exp(-Math.pow(x, 2.0) / 2.0);\n\t}\n",
+	},
+	apply: func(afunc, x string) string {
+		switch afunc {
+		case "identity":
+			return x
+		case "abs":
+			return "Math.abs(" + x + ")"
+		case "sine":
+			return "Math.sin(" + x + ")"
+		}
+		return afunc + "(" + x + ")"
+	},
+	assign: func(id int, expr string) string {
+		return fmt.Sprintf("const v%d = %s;", id, expr)
+	},
+	declare: func(name, expr string) string {
+		return fmt.Sprintf("let %s = %s;", name, expr)
+	},
+	set: func(name, expr string) string {
+		return fmt.Sprintf("%s = %s;", name, expr)
+	},
+	math: func(fn string) string {
+		return "Math." + fn
+	},
+	colors: map[string]string{
+		"hue": "\tfunction hue(h, chroma, m) {\n" +
+			"\t\th = 6.0 * (h - Math.floor(h));\n" +
+			"\t\tconst x = chroma * (1.0 - Math.abs(h % 2.0 - 1.0));\n" +
+			"\t\tlet c = [chroma, 0.0, x];\n" +
+			"\t\tif (h < 1.0) {\n\t\t\tc = [chroma, x, 0.0];\n" +
+			"\t\t} else if (h < 2.0) {\n\t\t\tc = [x, chroma, 0.0];\n" +
+			"\t\t} else if (h < 3.0) {\n\t\t\tc = [0.0, chroma, x];\n" +
+			"\t\t} else if (h < 4.0) {\n\t\t\tc = [0.0, x, chroma];\n" +
+			"\t\t} else if (h < 5.0) {\n\t\t\tc = [x, 0.0, chroma];\n" +
+			"\t\t}\n\t\treturn [c[0] + m, c[1] + m, c[2] + m];\n\t}\n",
+		"hsv": "\tfunction hsv(h, s, v) {\n" +
+			"\t\tconst chroma = v * s;\n" +
+			"\t\treturn hue(h, chroma, v - chroma);\n\t}\n",
+		"hsl": "\tfunction hsl(h, s, l) {\n" +
+			"\t\tconst chroma = (1.0 - Math.abs(2.0 * l - 1.0)) * s;\n" +
+			"\t\treturn hue(h, chroma, l - chroma / 2.0);\n\t}\n",
+	},
+}
+
+var goLanguage = &codeLanguage{
+	float: func(v float64) string {
+		return floatLiteral(v, "math.NaN()", "math.Inf(1)")
+	},
+	afuncs: map[string]string{
+		"sigmoid": "func sigmoid(x float64) float64 {\n" +
+			"\tx = math.Max(-60.0, math.Min(60.0, 5.0*x))\n" +
+			"\treturn 1.0 / (1.0 + math.Exp(-x))\n}\n",
+		"tanh": "func tanh(x float64) float64 {\n" +
+			"\tx = math.Max(-60.0, math.Min(60.0, 2.5*x))\n" +
+			"\treturn math.Tanh(x)\n}\n",
+		"relu": "func relu(x float64) float64 {\n" +
+			"\tif x >= 0.0 {\n\t\treturn x\n\t}\n\treturn 1.0\n}\n",
+		"elu": "func elu(x float64) float64 {\n" +
+			"\tif x >= 0.0 {\n\t\treturn x\n\t}\n" +
+			"\tx = math.Max(-60.0, math.Min(60.0, 2.5*x))\n" +
+			"\treturn math.Exp(x) - 1.0\n}\n",
+		"gaussian": "func gaussian(x float64) float64 {\n" +
+			"\tx = math.Max(-3.4, math.Min(3.4, x))\n" +
+			"\treturn (1.0 / math.Sqrt(2.0*math.Pi)) *\n" +
+			"\t\tmath.Exp(-math.Pow(x, 2.0)/2.0)\n}\n",
+	},
+	apply: func(afunc, x string) string {
+		switch afunc {
+		case "identity":
+			return x
+		case "abs":
+			return "math.Abs(" + x + ")"
+		case "sine":
+			return "math.Sin(" + x + ")"
+		}
+		return afunc + "(" + x + ")"
+	},
+	assign: func(id int, expr string) string {
+		return fmt.Sprintf("v%d := %s", id, expr)
+	},
+	declare: func(name, expr string) string {
+		return fmt.Sprintf("%s := %s", name, expr)
+	},
+	set: func(name, expr string) string {
+		return fmt.Sprintf("%s = %s", name, expr)
+	},
+	math: func(fn string) string {
+		return "math." + strings.ToUpper(fn[:1]) + fn[1:]
+	},
+}
+
+// coordinates returns the expressions of the coordinate inputs with the
+// argument input encoding, as in encodeInputs, given the expressions of the
+// coordinate, the size of the domain, and the time.
+func (lang *codeLanguage) coordinates(encoding, x, y, width, height,
+	t string) []string {
+	if encoding == "torus" {
+		angle := func(v, period string) string {
+			return fmt.Sprintf("6.283185307179586 * %s / %s", v, period)
+		}
+		return []string{
+			lang.math("sin") + "(" + angle(x, width) + ")",
+			lang.math("cos") + "(" + angle(x, width) + ")",
+			lang.math("sin") + "(" + angle(y, height) + ")",
+			lang.math("cos") + "(" + angle(y, height) + ")",
+			"1.0",
+			t,
+		}
+	}
+	dx := fmt.Sprintf("(%s - %s / 2.0)", x, width)
+	dy := fmt.Sprintf("(%s - %s / 2.0)", y, height)
+	d := fmt.Sprintf("%s(%s * %s + %s * %s)", lang.math("sqrt"), dx, dx, dy, dy)
+	return []string{x + " * 0.1", y + " * 0.1", d + " * 0.1", "1.0", t}
+}
+
+// fold returns the statements that fold the coordinate variables x and y
+// under the symmetry about the argument center, as in Symmetry.Fold,
+// indented by the argument prefix.
+func (lang *codeLanguage) fold(s Symmetry, x, y, cx, cy,
+	indent string) string {
+	mirror := func(v, c string) string {
+		return lang.set(v, fmt.Sprintf("%s + %s(%s - %s)", c, lang.math("abs"),
+			v, c))
+	}
+
+	var stmts []string
+	switch s.Kind {
+	case "mirror-x":
+		stmts = []string{mirror(x, cx)}
+	case "mirror-y":
+		stmts = []string{mirror(y, cy)}
+	case "mirror-xy":
+		stmts = []string{mirror(x, cx), mirror(y, cy)}
+	case "rotate", "kaleidoscope":
+		wedge := lang.float(s.wedge())
+		stmts = []string{
+			lang.declare("dx", x+" - "+cx),
+			lang.declare("dy", y+" - "+cy),
+			lang.declare("r", lang.math("sqrt")+"(dx * dx + dy * dy)"),
+			lang.declare("a", lang.math("atan2")+"(dy, dx)"),
+			lang.set("a", fmt.Sprintf("a - %s * %s(a / %s)", wedge,
+				lang.math("floor"), wedge)),
+		}
+		if s.Kind == "kaleidoscope" {
+			stmts = append(stmts, lang.set("a", fmt.Sprintf("%s(a, %s - a)",
+				lang.math("min"), wedge)))
+		}
+		stmts = append(stmts,
+			lang.set(x, cx+" + r * "+lang.math("cos")+"(a)"),
+			lang.set(y, cy+" + r * "+lang.math("sin")+"(a)"))
+	}
+
+	var b strings.Builder
+	for _, stmt := range stmts {
+		b.WriteString(indent + stmt + "\n")
+	}
+	return b.String()
+}
+
+// GLSL returns a standalone GLSL fragment shader that renders the genome in
+// the same way as draw with the argument input encoding, symmetry and color
+// space, from gl_FragCoord and the uniforms describing the viewport. Outputs
+// are mapped to RGB channels as in ColorSpace: with fewer than 3 outputs, the
+// first is gray, and an output following the colors is alpha. It returns an
+// error if the color space is not rgb, hsv or hsl.
+func (g *Genome) GLSL(encoding string, symmetry Symmetry,
+	cs ColorSpace) (string, error) {
+	p, err := newProgram(g)
+	if err != nil {
+		return "", err
+	}
+	numColors, alpha, err := codeColors(cs, len(p.Outputs))
+	if err != nil {
+		return "", err
+	}
+	lang := glslLanguage
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Generated by imagen from genome %d.\n\n", p.ID)
+	b.WriteString("#ifdef GL_ES\nprecision highp float;\n#endif\n\n")
+	b.WriteString("uniform vec2 uResolution; // size of the image in pixels\n")
+	b.WriteString("uniform vec2 uDomain;     // size of the coordinate domain\n")
+	b.WriteString("uniform float uZoom;      // zoom about the domain's center\n")
+	b.WriteString("uniform vec2 uPan;        // pan in domain coordinates\n")
+	b.WriteString("uniform float uRotation;  // clockwise rotation in radians\n")
+	b.WriteString("uniform float uTime;      // time input\n\n")
+	b.WriteString(p.helpers(lang))
+	b.WriteString(lang.colorHelpers(cs, numColors))
+
+	b.WriteString("void main() {\n")
+	b.WriteString("\t// pixel coordinates from the top left, as in draw\n")
+	b.WriteString("\tvec2 px = vec2(floor(gl_FragCoord.x), " +
+		"uResolution.y - 1.0 - floor(gl_FragCoord.y));\n")
+	b.WriteString("\tvec2 c = 0.5 * uDomain;\n")
+	b.WriteString("\tvec2 xy = px * uDomain / uResolution - c;\n")
+	b.WriteString("\tfloat s = sin(-uRotation), co = cos(-uRotation);\n")
+	b.WriteString("\txy = vec2(co * xy.x - s * xy.y, s * xy.x + co * xy.y) / uZoom " +
+		"+ c + uPan;\n")
+	b.WriteString("\t// the center of the pixel grid, about which symmetry is " +
+		"folded\n")
+	b.WriteString("\tvec2 g = 0.5 * (uResolution - 1.0) * uDomain / " +
+		"uResolution;\n")
+	b.WriteString(lang.fold(symmetry, "xy.x", "xy.y", "g.x", "g.y", "\t"))
+	b.WriteString("\n")
+	inputs := lang.coordinates(encoding, "xy.x", "xy.y", "uDomain.x",
+		"uDomain.y", "uTime")
+	for i, id := range p.Inputs {
+		expr := "0.0"
+		if i < len(inputs) {
+			expr = inputs[i]
+		}
+		b.WriteString("\t" + lang.assign(id, expr) + "\n")
+	}
+	b.WriteString(p.body(lang, "\t"))
+
+	out := func(i int) string {
+		return fmt.Sprintf("v%d", p.Outputs[i])
+	}
+	clamped := func(i int) string {
+		return fmt.Sprintf("clamp(v%d, 0.0, 1.0)", p.Outputs[i])
+	}
+	rgb := fmt.Sprintf("vec3(%s)", out(0))
+	switch {
+	case numColors < 3:
+	case cs.Name == "hsv" || cs.Name == "hsl":
+		rgb = fmt.Sprintf("%s_rgb(%s, %s, %s)", cs.Name, out(0), clamped(1),
+			clamped(2))
+	default:
+		rgb = fmt.Sprintf("vec3(%s, %s, %s)", out(0), out(1), out(2))
+	}
+	a := "1.0"
+	if alpha {
+		a = clamped(numColors)
+	}
+	fmt.Fprintf(&b, "\n\tgl_FragColor = vec4(clamp(%s, 0.0, 1.0), %s);\n}\n",
+		rgb, a)
+
+	return b.String(), nil
+}
+
+// JavaScript returns a standalone JavaScript module-less script that defines
+// imagenPattern, with functions to encode a coordinate into the inputs with
+// the argument input encoding and symmetry, to evaluate the genome's outputs,
+// to convert them to a color in the argument color space as in ColorSpace,
+// and to render it into a canvas as draw does. It returns an error if the
+// color space is not rgb, hsv or hsl.
+func (g *Genome) JavaScript(encoding string, symmetry Symmetry,
+	cs ColorSpace) (string, error) {
+	p, err := newProgram(g)
+	if err != nil {
+		return "", err
+	}
+	numColors, alpha, err := codeColors(cs, len(p.Outputs))
+	if err != nil {
+		return "", err
+	}
+	lang := jsLanguage
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Generated by imagen from genome %d.\n", p.ID)
+	b.WriteString("const imagenPattern = (function () {\n")
+	b.WriteString("\t\"use strict\";\n\n")
+	b.WriteString(p.helpers(lang))
+	b.WriteString(lang.colorHelpers(cs, numColors))
+
+	b.WriteString("\t// inputs encodes a coordinate in a domain of the " +
+		"argument size, with a pixel\n\t// per unit, as in training.\n")
+	b.WriteString("\tfunction inputs(x, y, width, height, t) {\n")
+	b.WriteString(lang.fold(symmetry, "x", "y", "((width - 1.0) / 2.0)",
+		"((height - 1.0) / 2.0)", "\t\t"))
+	fmt.Fprintf(&b, "\t\tconst inputs = [\n\t\t\t%s,\n\t\t];\n",
+		strings.Join(lang.coordinates(encoding, "x", "y", "width", "height",
+			"t"), ",\n\t\t\t"))
+	fmt.Fprintf(&b, "\t\twhile (inputs.length < %d) {\n\t\t\tinputs.push(0.0);\n"+
+		"\t\t}\n\t\treturn inputs.slice(0, %d);\n\t}\n\n",
+		len(p.Inputs), len(p.Inputs))
+
+	b.WriteString("\t// evaluate returns the outputs for the argument inputs.\n")
+	b.WriteString("\tfunction evaluate(inputs) {\n")
+	for i, id := range p.Inputs {
+		b.WriteString("\t\t" + lang.assign(id, fmt.Sprintf("inputs[%d]", i)) + "\n")
+	}
+	b.WriteString(p.body(lang, "\t\t"))
+	outputs := make([]string, len(p.Outputs))
+	for i, id := range p.Outputs {
+		outputs[i] = fmt.Sprintf("v%d", id)
+	}
+	fmt.Fprintf(&b, "\t\treturn [%s];\n\t}\n\n", strings.Join(outputs, ", "))
+
+	b.WriteString("\t// color returns the color in RGB and the alpha of the " +
+		"argument outputs,\n\t// clamped to [0, 1].\n")
+	b.WriteString("\tfunction color(out) {\n")
+	b.WriteString("\t\tconst clamp = (v) => Math.max(0.0, Math.min(1.0, v));\n")
+	rgb := "[out[0], out[0], out[0]]"
+	switch {
+	case numColors < 3:
+	case cs.Name == "hsv" || cs.Name == "hsl":
+		rgb = cs.Name + "(out[0], clamp(out[1]), clamp(out[2]))"
+	default:
+		rgb = "[out[0], out[1], out[2]]"
+	}
+	a := "1.0"
+	if alpha {
+		a = fmt.Sprintf("clamp(out[%d])", numColors)
+	}
+	fmt.Fprintf(&b, "\t\tconst rgb = %s;\n", rgb)
+	fmt.Fprintf(&b, "\t\treturn [clamp(rgb[0]), clamp(rgb[1]), clamp(rgb[2]), "+
+		"%s];\n\t}\n\n", a)
+
+	b.WriteString("\t// render renders the pattern into a 2D canvas context.\n")
+	b.WriteString("\tfunction render(ctx, width, height, t) {\n")
+	b.WriteString("\t\tconst img = ctx.createImageData(width, height);\n")
+	b.WriteString("\t\tfor (let y = 0; y < height; y++) {\n")
+	b.WriteString("\t\t\tfor (let x = 0; x < width; x++) {\n")
+	b.WriteString("\t\t\t\tconst c = color(evaluate(inputs(x, y, width, " +
+		"height, t || 0.0)));\n")
+	b.WriteString("\t\t\t\tconst i = 4 * (y * width + x);\n")
+	b.WriteString("\t\t\t\tfor (let k = 0; k < 4; k++) {\n")
+	b.WriteString("\t\t\t\t\timg.data[i + k] = Math.floor(255.0 * c[k]);\n")
+	b.WriteString("\t\t\t\t}\n")
+	b.WriteString("\t\t\t}\n\t\t}\n\t\tctx.putImageData(img, 0, 0);\n\t}\n\n")
+
+	b.WriteString("\treturn { inputs: inputs, evaluate: evaluate, color: color, " +
+		"render: render };\n")
+	b.WriteString("})();\n")
+
+	return b.String(), nil
+}
+
+// Go returns Go source code of a package with the argument name, which
+// defines Inputs to encode a coordinate into the inputs with the argument
+// input encoding and symmetry, and Eval to evaluate the genome's outputs.
+func (g *Genome) Go(pkg, encoding string, symmetry Symmetry) (string, error) {
+	p, err := newProgram(g)
+	if err != nil {
+		return "", err
+	}
+	lang := goLanguage
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Code generated by imagen from genome %d. DO NOT EDIT.\n\n",
+		p.ID)
+	fmt.Fprintf(&b, "package %s\n\nimport \"math\"\n\n", pkg)
+	fmt.Fprintf(&b, "// NumInputs is the number of inputs of Eval.\n"+
+		"const NumInputs = %d\n\n", len(p.Inputs))
+	fmt.Fprintf(&b, "// NumOutputs is the number of outputs of Eval.\n"+
+		"const NumOutputs = %d\n\n", len(p.Outputs))
+	b.WriteString(p.helpers(lang))
+
+	b.WriteString("// Inputs encodes a coordinate (x, y) in a domain of the " +
+		"argument size, with a\n// pixel per unit, as in training.\n")
+	b.WriteString("func Inputs(x, y, width, height, t float64) [NumInputs]float64 {\n")
+	b.WriteString(lang.fold(symmetry, "x", "y", "((width - 1.0) / 2.0)",
+		"((height - 1.0) / 2.0)", "\t"))
+	fmt.Fprintf(&b, "\tcoords := []float64{\n\t\t%s,\n\t}\n",
+		strings.Join(lang.coordinates(encoding, "x", "y", "width", "height",
+			"t"), ",\n\t\t"))
+	b.WriteString("\tvar inputs [NumInputs]float64\n")
+	b.WriteString("\tcopy(inputs[:], coords)\n\treturn inputs\n}\n\n")
+
+	b.WriteString("// Eval returns the outputs for the argument inputs.\n")
+	b.WriteString("func Eval(inputs [NumInputs]float64) [NumOutputs]float64 {\n")
+	for i, id := range p.Inputs {
+		b.WriteString("\t" + lang.assign(id, fmt.Sprintf("inputs[%d]", i)) + "\n")
+	}
+	b.WriteString(p.body(lang, "\t"))
+	outputs := make([]string, len(p.Outputs))
+	for i, id := range p.Outputs {
+		outputs[i] = fmt.Sprintf("v%d", id)
+	}
+	// node variables that are not used by any other node
+	used := make(map[int]bool)
+	for _, id := range p.Outputs {
+		used[id] = true
+	}
+	for _, n := range p.Nodes {
+		for _, t := range n.Terms {
+			used[t.Input] = true
+		}
+	}
+	unused := make([]string, 0)
+	for _, id := range p.Inputs {
+		if !used[id] {
+			unused = append(unused, fmt.Sprintf("v%d", id))
+		}
+	}
+	for _, n := range p.Nodes {
+		if !used[n.ID] {
+			unused = append(unused, fmt.Sprintf("v%d", n.ID))
+		}
+	}
+	if len(unused) > 0 {
+		blanks := strings.TrimSuffix(strings.Repeat("_, ", len(unused)), ", ")
+		fmt.Fprintf(&b, "\t%s = %s\n", blanks, strings.Join(unused, ", "))
+	}
+	fmt.Fprintf(&b, "\treturn [NumOutputs]float64{%s}\n}\n",
+		strings.Join(outputs, ", "))
+
+	return b.String(), nil
+}
+
+// codegen generates source code from an exported genome, in the language
+// given by the output file's extension. The input encoding, symmetry and
+// color space are the configuration's, if any, or else those the genome was
+// trained with.
+func codegen(args []string) error {
+	if len(args) != 2 && len(args) != 3 {
+		return errors.New("usage: imagen codegen [genome] " +
+			"[output](.frag|.glsl|.js|.go) [[config].json]")
+	}
+
+	g, meta, err := OpenGenome(args[0])
+	if err != nil {
+		return err
+	}
+	config := &Configuration{}
+	if len(args) == 3 {
+		if config, err = NewConfiguration(args[2]); err != nil {
+			return err
+		}
+	}
+	config.inherit(meta)
+	symmetry, err := ParseSymmetry(config.Symmetry)
+	if err != nil {
+		return err
+	}
+	cs, err := ParseColorSpace(config.ColorSpace, config.Palette)
+	if err != nil {
+		return err
+	}
+
+	var src string
+	switch filepath.Ext(args[1]) {
+	case ".frag", ".glsl":
+		src, err = g.GLSL(config.InputEncoding, symmetry, cs)
+	case ".js":
+		src, err = g.JavaScript(config.InputEncoding, symmetry, cs)
+	case ".go":
+		src, err = g.Go("pattern", config.InputEncoding, symmetry)
+	default:
+		return fmt.Errorf("unknown language of %s", args[1])
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(args[1], []byte(src), 0644)
+}
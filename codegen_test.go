@@ -0,0 +1,243 @@
+/*
+
+
+codegen_test.go tests for source code generation.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"math"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gonum/matrix/mat64"
+)
+
+// codegenHarness prints the outputs of the generated Go code for each pixel
+// of a 4x4 image, one output per line.
+const codegenHarness = `package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+func main() {
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			out := Eval(Inputs(float64(x), float64(y), 4.0, 4.0, 0.0))
+			for _, v := range out {
+				fmt.Println(strconv.FormatFloat(v, 'g', -1, 64))
+			}
+		}
+	}
+}
+`
+
+func TestGenomeGo(t *testing.T) {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command is not available")
+	}
+	rand.Seed(0)
+
+	for i := 0; i < 6; i++ {
+		encoding := []string{"cartesian", "torus"}[i%2]
+		symmetry, err := ParseSymmetry([]string{"none", "mirror-xy",
+			"kaleidoscope-5"}[i/2])
+		if err != nil {
+			t.Fatal(err)
+		}
+		g0 := NewGenome(0, 4, 4, 3)
+		g1 := NewGenome(1, 4, 4, 3)
+		for j := 0; j < 20; j++ {
+			g0.Mutate(0.5, 0.5)
+			g1.Mutate(0.5, 0.5)
+		}
+		g0.Crossover(g1)
+
+		src, err := g0.Go("main", encoding, symmetry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dir := t.TempDir()
+		files := map[string]string{
+			"go.mod":     "module pattern\n",
+			"pattern.go": src,
+			"main.go":    codegenHarness,
+		}
+		for name, content := range files {
+			err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		cmd := exec.Command(goBin, "run", ".")
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("generated code failed: %v\n%s\n%s", err, out, src)
+		}
+		lines := strings.Fields(string(out))
+
+		n, err := NewDPPN(g0, 16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs := make([]float64, 16*4)
+		for j := 0; j < 16; j++ {
+			x, y := symmetry.Fold(float64(j%4), float64(j/4), 1.5, 1.5)
+			encodeInputs(encoding, x, y, 4.0, 4.0, 0.0, nil,
+				inputs[j*4:(j+1)*4])
+		}
+		expected, err := n.FeedForward(mat64.NewDense(16, 4, inputs))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := expected.RawMatrix().Data
+		if len(lines) != len(data) {
+			t.Fatalf("generated code printed %d outputs, expected %d",
+				len(lines), len(data))
+		}
+		for j, line := range lines {
+			v, err := strconv.ParseFloat(line, 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(v-data[j]) > 1e-9 {
+				t.Errorf("output %d of generated code is %g, expected %g",
+					j, v, data[j])
+			}
+		}
+	}
+}
+
+func TestGenomeGLSL(t *testing.T) {
+	rand.Seed(0)
+
+	g := NewGenome(0, 4, 4, 3)
+	for j := 0; j < 20; j++ {
+		g.Mutate(0.5, 0.5)
+	}
+	symmetry := Symmetry{Kind: "kaleidoscope", Order: 6}
+	gens := []func(string, Symmetry, ColorSpace) (string, error){g.GLSL,
+		g.JavaScript}
+	for _, gen := range gens {
+		src, err := gen("torus", symmetry, ColorSpace{Name: "hsl"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(src, "hsl") {
+			t.Error("hsl is not converted to RGB in generated code")
+		}
+		if !strings.Contains(src, "floor(a / ") {
+			t.Error("symmetry is not folded in generated code")
+		}
+		for _, node := range g.NodeGenes {
+			if !strings.Contains(src, "v"+strconv.Itoa(node.ID)+" = ") {
+				t.Errorf("node %d is not assigned in generated code", node.ID)
+			}
+		}
+		if _, err := gen("torus", symmetry, ColorSpace{Name: "lab"}); err == nil {
+			t.Error("lab outputs are generated without conversion")
+		}
+	}
+}
+
+// codegenCanvas renders the generated JavaScript pattern into a fake canvas
+// of 8x8 pixels, and prints its channels, one per line.
+const codegenCanvas = `
+imagenPattern.render({
+	createImageData: (width, height) => ({
+		data: new Uint8ClampedArray(4 * width * height),
+	}),
+	putImageData: (img) => console.log(img.data.join("\n")),
+}, 8, 8);
+`
+
+func TestGenomeJavaScript(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node command is not available")
+	}
+	rand.Seed(0)
+
+	for _, numOutputs := range []int{3, 4} {
+		g := NewGenome(0, 4, 4, numOutputs)
+		for j := 0; j < 20; j++ {
+			g.Mutate(0.5, 0.5)
+		}
+		opts := NewRenderOptions(8, 8)
+		opts.ColorSpace = ColorSpace{Name: "hsv"}
+		src, err := g.JavaScript(opts.Encoding, opts.Symmetry, opts.ColorSpace)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cmd := exec.Command(node)
+		cmd.Stdin = strings.NewReader(src + codegenCanvas)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("generated code failed: %v\n%s\n%s", err, out, src)
+		}
+		lines := strings.Fields(string(out))
+
+		// the canvas is not premultiplied by alpha
+		expected := render(g, opts)
+		if len(lines) != len(expected.Pix) {
+			t.Fatalf("generated code printed %d channels, expected %d",
+				len(lines), len(expected.Pix))
+		}
+		for i := 0; i < len(lines); i += 4 {
+			c := make([]int, 4)
+			for k := range c {
+				if c[k], err = strconv.Atoi(lines[i+k]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for k := 0; k < 3; k++ {
+				c[k] = c[k] * c[3] / 255
+			}
+			for k, v := range c {
+				if d := v - int(expected.Pix[i+k]); d < -2 || d > 2 {
+					t.Errorf("channel %d of %d outputs is %d, expected %d",
+						i+k, numOutputs, v, expected.Pix[i+k])
+				}
+			}
+		}
+	}
+}
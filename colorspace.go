@@ -0,0 +1,481 @@
+/*
+
+
+colorspace.go implementation of output color spaces.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// colorSpaces contains the names of the color spaces of DPPN outputs.
+var colorSpaces = map[string]bool{
+	"rgb":     true,
+	"hsv":     true,
+	"hsl":     true,
+	"lab":     true,
+	"palette": true,
+}
+
+// ColorSpace is the interpretation of a DPPN's outputs as colors. Outputs in
+// rgb, hsv, hsl and lab are three channels in [0, 1]: hue is a fraction of a
+// turn, and CIELAB (D65) is scaled as L / 100, a / 256 + 0.5 and b / 256 +
+// 0.5. With fewer than 3 outputs, the color is gray, given by its luma in
+// [0, 1] (L / 100 in lab). Outputs in palette are scores of each color of
+// the palette, of which the highest scoring color is rendered.
+//
+// Training minimizes the mean squared error of the outputs, where the error
+// of hue is circular, so that hue 0.95 is as far from 0.05 as 0.15. In
+// palette, the target is 1 for the nearest color and 0 for the others, so
+// that the error does not depend on how near the other colors are.
+//
+// The outputs following the color outputs are mapped by their number: the
+// first is alpha in [0, 1], by which the colors are not premultiplied, and
+// the others are auxiliary outputs, which are neither trained against the
+// target image nor rendered. Hence, 1 output is gray, 2 gray and alpha, 3
+// RGB, and 4 RGBA.
+type ColorSpace struct {
+	Name    string       // rgb (default), hsv, hsl, lab or palette
+	Palette []color.RGBA // colors of palette outputs
+}
+
+// ParseColorSpace returns the color space of the argument name, and palette
+// of colors in hexadecimal (#rrggbb). An empty name is rgb.
+func ParseColorSpace(name string, palette []string) (ColorSpace, error) {
+	if name == "" {
+		name = "rgb"
+	}
+	if !colorSpaces[name] {
+		return ColorSpace{}, fmt.Errorf("unknown color space %s", name)
+	}
+	cs := ColorSpace{Name: name}
+	for _, s := range palette {
+		c, err := parseHexColor(s)
+		if err != nil {
+			return ColorSpace{}, err
+		}
+		cs.Palette = append(cs.Palette, c)
+	}
+	return cs, nil
+}
+
+// parseHexColor parses an opaque color in hexadecimal (#rrggbb).
+func parseHexColor(s string) (color.RGBA, error) {
+	if len(s) != 7 || s[0] != '#' {
+		return color.RGBA{}, fmt.Errorf("invalid color %s", s)
+	}
+	v, err := strconv.ParseUint(s[1:], 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid color %s", s)
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}, nil
+}
+
+// hexColor returns the argument color in hexadecimal (#rrggbb).
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// Channels returns the number of color outputs of a DPPN with the argument
+// number of outputs, whether an alpha output follows them, and the number of
+// auxiliary outputs following alpha.
+func (cs ColorSpace) Channels(numOutputs int) (int, bool, int) {
+	numColors := 3
+	switch {
+	case cs.Name == "palette":
+		numColors = len(cs.Palette)
+		if numColors > numOutputs {
+			numColors = numOutputs
+		}
+	case numOutputs < 3:
+		numColors = 1
+	}
+	alpha := numOutputs > numColors
+	numAux := 0
+	if alpha {
+		numAux = numOutputs - numColors - 1
+	}
+	return numColors, alpha, numAux
+}
+
+// Validate returns an error if a DPPN with the argument number of outputs
+// has too few outputs for the color space.
+func (cs ColorSpace) Validate(numOutputs int) error {
+	if numOutputs < 1 {
+		return fmt.Errorf("invalid number of outputs %d", numOutputs)
+	}
+	if cs.Name == "palette" && numOutputs < len(cs.Palette) {
+		return fmt.Errorf("palette of %d colors for %d outputs",
+			len(cs.Palette), numOutputs)
+	}
+	return nil
+}
+
+// Encode writes the outputs that represent the argument color into the
+// argument slice of all outputs, which are the targets of training.
+// Auxiliary outputs are not written. In palette, the nearest color of the
+// palette scores 1, and the others 0.
+func (cs ColorSpace) Encode(c color.NRGBA, outputs []float64) {
+	numColors, alpha, _ := cs.Channels(len(outputs))
+	if alpha {
+		outputs[numColors] = float64(c.A) / 255.0
+	}
+
+	r, g, b := float64(c.R)/255.0, float64(c.G)/255.0, float64(c.B)/255.0
+	switch {
+	case cs.Name == "palette":
+		nearest, best := 0, math.Inf(1)
+		for i, p := range cs.Palette {
+			dr, dg, db := float64(c.R)-float64(p.R), float64(c.G)-float64(p.G),
+				float64(c.B)-float64(p.B)
+			if d := dr*dr + dg*dg + db*db; d < best {
+				nearest, best = i, d
+			}
+			outputs[i] = 0.0
+		}
+		outputs[nearest] = 1.0
+	case numColors == 1 && cs.Name == "lab":
+		l, _, _ := rgbToLab(r, g, b)
+		outputs[0] = l / 100.0
+	case numColors == 1:
+		outputs[0] = luma(r, g, b)
+	case cs.Name == "hsv":
+		outputs[0], outputs[1], outputs[2] = rgbToHSV(r, g, b)
+	case cs.Name == "hsl":
+		outputs[0], outputs[1], outputs[2] = rgbToHSL(r, g, b)
+	case cs.Name == "lab":
+		l, a, bb := rgbToLab(r, g, b)
+		outputs[0], outputs[1], outputs[2] = l/100.0, a/256.0+0.5, bb/256.0+0.5
+	default:
+		outputs[0], outputs[1], outputs[2] = r, g, b
+	}
+}
+
+// Circular returns whether the first output of a DPPN with the argument
+// number of outputs is hue, of which the error is circular.
+func (cs ColorSpace) Circular(numOutputs int) bool {
+	numColors, _, _ := cs.Channels(numOutputs)
+	return numColors == 3 && (cs.Name == "hsv" || cs.Name == "hsl")
+}
+
+// AlignHue moves the hue of the argument targets by whole turns to the
+// nearest to the argument hue output, so that the error of hue is circular.
+// The targets are unchanged if the color space has no hue.
+func (cs ColorSpace) AlignHue(target []float64, h float64) {
+	if cs.Circular(len(target)) {
+		target[0] += math.Round(h - target[0])
+	}
+}
+
+// RGBA returns the color in RGB, not premultiplied, and the alpha of the
+// argument outputs. Colors converted from other color spaces, and alpha, are
+// clamped to [0, 1]. Alpha is 1 without an alpha output.
+func (cs ColorSpace) RGBA(outputs []float64) (r, g, b, a float64) {
+	numColors, alpha, _ := cs.Channels(len(outputs))
+	a = 1.0
+	if alpha {
+		a = clamp01(outputs[numColors])
+	}
+	r, g, b = cs.rgb(outputs[:numColors])
+	return r, g, b, a
+}
+
+// rgb returns the color in RGB of the argument color outputs.
+func (cs ColorSpace) rgb(outputs []float64) (float64, float64, float64) {
+	switch {
+	case cs.Name == "palette":
+		best := -1
+		for i := 0; i < len(outputs) && i < len(cs.Palette); i++ {
+			if best < 0 || outputs[i] > outputs[best] {
+				best = i
+			}
+		}
+		if best < 0 {
+			return 0.0, 0.0, 0.0
+		}
+		p := cs.Palette[best]
+		return float64(p.R) / 255.0, float64(p.G) / 255.0, float64(p.B) / 255.0
+	case len(outputs) == 1 && cs.Name == "lab":
+		// the luminance of gray, which is the same in every component
+		y := whiteY * labFInv((100.0*outputs[0]+16.0)/116.0)
+		v := linearToSRGB(clamp01(y))
+		return v, v, v
+	case len(outputs) == 1:
+		return outputs[0], outputs[0], outputs[0]
+	case cs.Name == "hsv":
+		return hsvToRGB(outputs[0], clamp01(outputs[1]), clamp01(outputs[2]))
+	case cs.Name == "hsl":
+		return hslToRGB(outputs[0], clamp01(outputs[1]), clamp01(outputs[2]))
+	case cs.Name == "lab":
+		return labToRGB(100.0*outputs[0], 256.0*(outputs[1]-0.5),
+			256.0*(outputs[2]-0.5))
+	}
+	return outputs[0], outputs[1], outputs[2]
+}
+
+// luma returns the luma of a color in sRGB (Rec. 709).
+func luma(r, g, b float64) float64 {
+	return 0.2126*r + 0.7152*g + 0.0722*b
+}
+
+// clamp01 clamps the argument value to [0, 1].
+func clamp01(v float64) float64 {
+	return math.Max(0.0, math.Min(1.0, v))
+}
+
+// rgbToHSV converts a color in RGB to hue, saturation and value in [0, 1].
+func rgbToHSV(r, g, b float64) (float64, float64, float64) {
+	max, min := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	h, s := hue(r, g, b, max, min), 0.0
+	if max > 0.0 {
+		s = (max - min) / max
+	}
+	return h, s, max
+}
+
+// rgbToHSL converts a color in RGB to hue, saturation and lightness in
+// [0, 1].
+func rgbToHSL(r, g, b float64) (float64, float64, float64) {
+	max, min := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	h, s, l := hue(r, g, b, max, min), 0.0, (max+min)/2.0
+	if max > min {
+		// clamped against rounding errors of the denominator
+		s = clamp01((max - min) / (1.0 - math.Abs(2.0*l-1.0)))
+	}
+	return h, s, l
+}
+
+// hue returns the hue in [0, 1) of a color in RGB, given its largest and
+// smallest components.
+func hue(r, g, b, max, min float64) float64 {
+	d := max - min
+	if d == 0.0 {
+		return 0.0
+	}
+	var h float64
+	switch max {
+	case r:
+		h = (g - b) / d
+	case g:
+		h = (b-r)/d + 2.0
+	default:
+		h = (r-g)/d + 4.0
+	}
+	h /= 6.0
+	return h - math.Floor(h)
+}
+
+// hueToRGB returns the color in RGB of the argument hue, chroma, and
+// smallest component. Hue wraps around, so that it is periodic.
+func hueToRGB(h, chroma, min float64) (float64, float64, float64) {
+	h = 6.0 * (h - math.Floor(h))
+	x := chroma * (1.0 - math.Abs(math.Mod(h, 2.0)-1.0))
+	var r, g, b float64
+	switch {
+	case h < 1.0:
+		r, g = chroma, x
+	case h < 2.0:
+		r, g = x, chroma
+	case h < 3.0:
+		g, b = chroma, x
+	case h < 4.0:
+		g, b = x, chroma
+	case h < 5.0:
+		r, b = x, chroma
+	default:
+		r, b = chroma, x
+	}
+	return r + min, g + min, b + min
+}
+
+// hsvToRGB converts a color in hue, saturation and value to RGB.
+func hsvToRGB(h, s, v float64) (float64, float64, float64) {
+	chroma := v * s
+	return hueToRGB(h, chroma, v-chroma)
+}
+
+// hslToRGB converts a color in hue, saturation and lightness to RGB.
+func hslToRGB(h, s, l float64) (float64, float64, float64) {
+	chroma := (1.0 - math.Abs(2.0*l-1.0)) * s
+	return hueToRGB(h, chroma, l-chroma/2.0)
+}
+
+// The reference white of CIELAB is D65, as in sRGB.
+const (
+	whiteX = 0.95047
+	whiteY = 1.0
+	whiteZ = 1.08883
+)
+
+// rgbToLab converts a color in sRGB to CIELAB.
+func rgbToLab(r, g, b float64) (float64, float64, float64) {
+	r, g, b = srgbToLinear(r), srgbToLinear(g), srgbToLinear(b)
+	x := 0.4124564*r + 0.3575761*g + 0.1804375*b
+	y := 0.2126729*r + 0.7151522*g + 0.0721750*b
+	z := 0.0193339*r + 0.1191920*g + 0.9503041*b
+
+	fx, fy, fz := labF(x/whiteX), labF(y/whiteY), labF(z/whiteZ)
+	return 116.0*fy - 16.0, 500.0 * (fx - fy), 200.0 * (fy - fz)
+}
+
+// labToRGB converts a color in CIELAB to sRGB, clamped to [0, 1].
+func labToRGB(l, a, b float64) (float64, float64, float64) {
+	fy := (l + 16.0) / 116.0
+	fx, fz := fy+a/500.0, fy-b/200.0
+	x, y, z := whiteX*labFInv(fx), whiteY*labFInv(fy), whiteZ*labFInv(fz)
+
+	r := 3.2404542*x - 1.5371385*y - 0.4985314*z
+	g := -0.9692660*x + 1.8760108*y + 0.0415560*z
+	bl := 0.0556434*x - 0.2040259*y + 1.0572252*z
+	return linearToSRGB(clamp01(r)), linearToSRGB(clamp01(g)),
+		linearToSRGB(clamp01(bl))
+}
+
+// labEpsilon and labKappa are the constants of CIELAB's piecewise function.
+const (
+	labEpsilon = 216.0 / 24389.0
+	labKappa   = 24389.0 / 27.0
+)
+
+func labF(t float64) float64 {
+	if t > labEpsilon {
+		return math.Cbrt(t)
+	}
+	return (labKappa*t + 16.0) / 116.0
+}
+
+func labFInv(t float64) float64 {
+	if t3 := t * t * t; t3 > labEpsilon {
+		return t3
+	}
+	return (116.0*t - 16.0) / labKappa
+}
+
+// srgbToLinear removes the gamma of an sRGB component.
+func srgbToLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// linearToSRGB applies the gamma of sRGB to a linear component.
+func linearToSRGB(v float64) float64 {
+	if v <= 0.0031308 {
+		return 12.92 * v
+	}
+	return 1.055*math.Pow(v, 1.0/2.4) - 0.055
+}
+
+// ExtractPalette returns a palette of n colors of the argument image, by
+// median cut: the box of colors with the widest range of a component is
+// split at its median, until there are n boxes, of which each color is the
+// mean. Colors are not premultiplied by alpha, and transparent pixels are
+// left out. The palette repeats its last color if the image has fewer
+// pixels.
+func ExtractPalette(img image.Image, n int) []color.RGBA {
+	bounds := img.Bounds()
+	pixels := make([]color.RGBA, 0, bounds.Dx()*bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A == 0 {
+				continue
+			}
+			pixels = append(pixels, color.RGBA{c.R, c.G, c.B, 255})
+		}
+	}
+	component := func(c color.RGBA, i int) uint8 {
+		return [3]uint8{c.R, c.G, c.B}[i]
+	}
+
+	boxes := [][]color.RGBA{pixels}
+	for len(boxes) < n {
+		// the box with the widest range of a component
+		split, axis, widest := -1, 0, -1
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			for c := 0; c < 3; c++ {
+				lo, hi := uint8(255), uint8(0)
+				for _, p := range box {
+					v := component(p, c)
+					if v < lo {
+						lo = v
+					}
+					if v > hi {
+						hi = v
+					}
+				}
+				if int(hi-lo) > widest {
+					split, axis, widest = i, c, int(hi-lo)
+				}
+			}
+		}
+		if split < 0 {
+			break
+		}
+
+		box := boxes[split]
+		sort.SliceStable(box, func(i, j int) bool {
+			return component(box[i], axis) < component(box[j], axis)
+		})
+		boxes[split] = box[:len(box)/2]
+		boxes = append(boxes, box[len(box)/2:])
+	}
+
+	palette := make([]color.RGBA, 0, n)
+	for _, box := range boxes {
+		if len(box) == 0 {
+			continue
+		}
+		var r, g, b int
+		for _, p := range box {
+			r, g, b = r+int(p.R), g+int(p.G), b+int(p.B)
+		}
+		k := len(box)
+		palette = append(palette, color.RGBA{uint8((r + k/2) / k),
+			uint8((g + k/2) / k), uint8((b + k/2) / k), 255})
+	}
+	for len(palette) > 0 && len(palette) < n {
+		palette = append(palette, palette[len(palette)-1])
+	}
+	return palette
+}
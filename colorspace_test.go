@@ -0,0 +1,252 @@
+/*
+
+
+colorspace_test.go tests for output color spaces.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestColorSpaceRoundTrip(t *testing.T) {
+	rand.Seed(0)
+
+	for _, name := range []string{"rgb", "hsv", "hsl", "lab"} {
+		cs, err := ParseColorSpace(name, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		alpha := name == "hsl"
+		outputs := make([]float64, 3)
+		if alpha {
+			outputs = append(outputs, 0.0)
+		}
+		for i := 0; i < 1000; i++ {
+			c := color.NRGBA{uint8(rand.Intn(256)), uint8(rand.Intn(256)),
+				uint8(rand.Intn(256)), 255}
+			if alpha {
+				c.A = uint8(rand.Intn(256))
+			}
+			cs.Encode(c, outputs)
+			for _, v := range outputs {
+				if v < 0.0 || v > 1.0 {
+					t.Fatalf("%s outputs of %v are %v, not in [0, 1]",
+						name, c, outputs)
+				}
+			}
+			r, g, b, a := cs.RGBA(outputs)
+			if math.Abs(r*255.0-float64(c.R)) > 1e-3 ||
+				math.Abs(g*255.0-float64(c.G)) > 1e-3 ||
+				math.Abs(b*255.0-float64(c.B)) > 1e-3 ||
+				math.Abs(a*255.0-float64(c.A)) > 1e-3 {
+				t.Fatalf("%s converts %v to %v and back to (%f, %f, %f, %f)",
+					name, c, outputs, r, g, b, a)
+			}
+		}
+	}
+}
+
+func TestColorSpaceLab(t *testing.T) {
+	for _, c := range []struct {
+		r, g, b  float64
+		l, a, bb float64
+	}{
+		{1.0, 1.0, 1.0, 100.0, 0.0, 0.0},
+		{1.0, 0.0, 0.0, 53.2408, 80.0925, 67.2032},
+		{0.0, 0.0, 1.0, 32.2970, 79.1875, -107.8602},
+	} {
+		l, a, b := rgbToLab(c.r, c.g, c.b)
+		if math.Abs(l-c.l) > 1e-3 || math.Abs(a-c.a) > 1e-3 ||
+			math.Abs(b-c.bb) > 1e-3 {
+			t.Errorf("(%f, %f, %f) in CIELAB is (%f, %f, %f), expected "+
+				"(%f, %f, %f)", c.r, c.g, c.b, l, a, b, c.l, c.a, c.bb)
+		}
+	}
+}
+
+func TestColorSpaceChannels(t *testing.T) {
+	rgb := ColorSpace{Name: "rgb"}
+	palette := ColorSpace{Name: "palette",
+		Palette: []color.RGBA{{0, 0, 0, 255}, {255, 255, 255, 255}}}
+	for _, c := range []struct {
+		cs         ColorSpace
+		numOutputs int
+		numColors  int
+		alpha      bool
+		numAux     int
+	}{
+		{rgb, 1, 1, false, 0},
+		{rgb, 2, 1, true, 0},
+		{rgb, 3, 3, false, 0},
+		{rgb, 4, 3, true, 0},
+		{rgb, 6, 3, true, 2},
+		{palette, 2, 2, false, 0},
+		{palette, 4, 2, true, 1},
+	} {
+		numColors, alpha, numAux := c.cs.Channels(c.numOutputs)
+		if numColors != c.numColors || alpha != c.alpha || numAux != c.numAux {
+			t.Errorf("%d outputs in %s are (%d, %v, %d), expected "+
+				"(%d, %v, %d)", c.numOutputs, c.cs.Name, numColors, alpha,
+				numAux, c.numColors, c.alpha, c.numAux)
+		}
+	}
+	if err := rgb.Validate(0); err == nil {
+		t.Error("no outputs are valid")
+	}
+
+	// gray is the luma of the target, or its lightness in lab
+	outputs := make([]float64, 2)
+	for _, name := range []string{"rgb", "lab"} {
+		cs := ColorSpace{Name: name}
+		cs.Encode(color.NRGBA{255, 255, 255, 51}, outputs)
+		if math.Abs(outputs[0]-1.0) > 1e-6 || outputs[1] != 0.2 {
+			t.Errorf("gray and alpha outputs of white in %s are %v",
+				name, outputs)
+		}
+		r, g, b, a := cs.RGBA([]float64{0.5, 0.2})
+		if r != g || g != b || a != 0.2 {
+			t.Errorf("gray in %s is (%f, %f, %f, %f)", name, r, g, b, a)
+		}
+	}
+}
+
+func TestColorSpacePalette(t *testing.T) {
+	palette := []string{"#000000", "#ff0000", "#00ff00", "#0000ff"}
+	cs, err := ParseColorSpace("palette", palette)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cs.Validate(3); err == nil {
+		t.Error("palette of 4 colors is valid for 3 outputs")
+	}
+
+	outputs := make([]float64, 4)
+	cs.Encode(color.NRGBA{200, 30, 40, 255}, outputs)
+	if outputs[0] != 0.0 || outputs[1] != 1.0 || outputs[2] != 0.0 ||
+		outputs[3] != 0.0 {
+		t.Errorf("nearest palette color of red is scored %v", outputs)
+	}
+	if r, g, b, _ := cs.RGBA([]float64{0.1, -2.0, 0.2, 0.7}); r != 0.0 ||
+		g != 0.0 || b != 1.0 {
+		t.Errorf("highest scoring palette color is (%f, %f, %f), "+
+			"expected blue", r, g, b)
+	}
+
+	for _, s := range []string{"ff0000", "#ff00", "#gg0000"} {
+		if _, err := ParseColorSpace("palette", []string{s}); err == nil {
+			t.Errorf("invalid color %s is parsed", s)
+		}
+	}
+	if _, err := ParseColorSpace("cmyk", nil); err == nil {
+		t.Error("unknown color space is parsed")
+	}
+}
+
+func TestExtractPalette(t *testing.T) {
+	colors := []color.RGBA{{255, 0, 0, 255}, {0, 255, 0, 255},
+		{0, 0, 255, 255}, {255, 255, 255, 255}}
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			img.SetRGBA(x, y, colors[2*(y/4)+x/4])
+		}
+	}
+
+	palette := ExtractPalette(img, 4)
+	if len(palette) != 4 {
+		t.Fatalf("palette has %d colors, expected 4", len(palette))
+	}
+	for _, c := range colors {
+		found := false
+		for _, p := range palette {
+			found = found || p == c
+		}
+		if !found {
+			t.Errorf("color %v of the image is not in palette %v", c, palette)
+		}
+	}
+
+	if palette := ExtractPalette(img, 100); len(palette) != 100 {
+		t.Errorf("palette has %d colors, expected 100", len(palette))
+	}
+
+	// transparent pixels are left out, and the others are not premultiplied
+	nrgba := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			if y < 2 {
+				nrgba.SetNRGBA(x, y, color.NRGBA{200, 100, 50, 128})
+			}
+		}
+	}
+	palette = ExtractPalette(nrgba, 2)
+	for _, p := range palette {
+		if p != (color.RGBA{200, 100, 50, 255}) {
+			t.Errorf("palette of a translucent image is %v", palette)
+			break
+		}
+	}
+}
+
+func TestColorSpaceAlignHue(t *testing.T) {
+	for _, c := range []struct {
+		name        string
+		numOutputs  int
+		target, out float64
+		expected    float64
+	}{
+		{"hsv", 3, 0.05, 0.95, 1.05},
+		{"hsl", 4, 0.95, 0.05, -0.05},
+		{"hsv", 3, 0.2, 2.3, 2.2},
+		{"hsv", 3, 0.5, 0.6, 0.5},
+		{"rgb", 3, 0.05, 0.95, 0.05},
+		{"hsv", 1, 0.05, 0.95, 0.05},
+	} {
+		cs, err := ParseColorSpace(c.name, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		target := make([]float64, c.numOutputs)
+		target[0] = c.target
+		cs.AlignHue(target, c.out)
+		if math.Abs(target[0]-c.expected) > 1e-9 {
+			t.Errorf("%s target %f for output %f is %f, expected %f", c.name,
+				c.target, c.out, target[0], c.expected)
+		}
+	}
+}
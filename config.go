@@ -2,16 +2,28 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
 	"io"
+	"math"
 	"os"
+	"reflect"
+	"strings"
 )
 
 // Config is a container for all configurations of microbial Genetic
 // Algorithm (mGA) and DPPN. It is initialized via importing a JSON file.
 type Configuration struct {
+	// JSON Schema of the file, if any, for editor support
+	Schema string `json:"$schema,omitempty"`
+
 	// Random Seed
 	Seed int64
 
+	// Debug mode validates genomes after every crossover and mutation
+	Debug bool
+
 	// mGA configurations
 	NumInputs      int     // number of inputs
 	NumOutputs     int     // number of outputs
@@ -21,14 +33,85 @@ type Configuration struct {
 	MutAddNodeRate float64 // mutation rate for adding an node
 	MutAddEdgeRate float64 // mutation rate for adding an edge
 	CrossoverRate  float64 // crossover rate
+	NumGenerations int     // number of generations (NSGA-II)
 
 	// DPPN configurations
 	NumEpochs    int     // number of training epochs
 	BatchSize    int     // size of each training batch
 	LearningRate float64 // learning rate (alpha)
+
+	// Genome store configurations
+	StorePath string // path of the genome store, if any
+
+	// Novelty search configurations
+	Width              int     // width of the training domain, or the image's
+	Height             int     // height of the training domain, or the image's
+	NoveltyK           int     // number of nearest neighbors
+	NoveltyArchiveSize int     // maximum number of archived descriptors
+	NoveltyResolution  int     // width and height of each descriptor
+	NoveltyBlend       float64 // weight of reconstruction error in [0, 1]
+
+	// Input encoding of coordinates, for both training and rendering
+	InputEncoding string // cartesian (default) or torus for tileable textures
+
+	// Symmetry of coordinates, for both training and rendering: none
+	// (default), mirror-x, mirror-y, mirror-xy, rotate-N or kaleidoscope-N
+	Symmetry string
+
+	// Output color space, for both training and rendering; the palette is
+	// extracted from the target image if it is empty
+	ColorSpace string   // rgb (default), hsv, hsl, lab or palette
+	Palette    []string // colors (#rrggbb) of palette outputs
+
+	// Final render configurations
+	RenderSamples int    // samples per pixel along each axis, or 1 if zero
+	RenderJitter  bool   // jitter samples within their grid cells
+	RenderFilter  string // reconstruction filter (box, tent), or box if empty
+	RenderDepth   int    // bits per channel of PNG images (8, 16), or 8 if zero
+
+	// Final render viewport configurations
+	RenderZoom         float64    // zoom about the center, or 1 if zero
+	RenderPanX         float64    // horizontal pan in domain coordinates
+	RenderPanY         float64    // vertical pan in domain coordinates
+	RenderRotation     float64    // clockwise rotation in radians
+	RenderWarp         string     // genome warping the domain, loaded to render
+	RenderWarpStrength float64    // scale of the domain warp, or 1 if zero
+	RenderPath         CameraPath // keyframes of animations
+}
+
+// DefaultConfiguration returns the configuration of fields that are omitted
+// from configuration files.
+func DefaultConfiguration() *Configuration {
+	return &Configuration{
+		NumInputs:          4,
+		NumOutputs:         3,
+		NumInitHidden:      4,
+		PopulationSize:     50,
+		NumTournaments:     500,
+		MutAddNodeRate:     0.3,
+		MutAddEdgeRate:     0.5,
+		CrossoverRate:      0.2,
+		NumGenerations:     10,
+		NumEpochs:          2000,
+		BatchSize:          8,
+		LearningRate:       0.1,
+		Width:              128,
+		Height:             128,
+		NoveltyK:           15,
+		NoveltyArchiveSize: 1000,
+		NoveltyResolution:  16,
+		NoveltyBlend:       0.0,
+		RenderSamples:      1,
+		RenderFilter:       "box",
+		RenderDepth:        8,
+		RenderZoom:         1.0,
+		RenderWarpStrength: 1.0,
+	}
 }
 
 // NewConfiguration creates a new configuration struct given a JSON filename.
+// Omitted fields are set to their defaults. It returns an error if the file
+// is empty, has unknown fields, or if the configuration is invalid.
 func NewConfiguration(filename string) (*Configuration, error) {
 	// import configuration
 	f, err := os.Open(filename)
@@ -37,13 +120,258 @@ func NewConfiguration(filename string) (*Configuration, error) {
 	}
 	defer f.Close()
 	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+
+	config := DefaultConfiguration()
+	if err := dec.Decode(config); err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("%s: empty configuration", filename)
+		}
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("%s: data after the configuration", filename)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// ConfigError lists the problems of a configuration.
+type ConfigError struct {
+	Problems []string // invalid fields and options
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// configRange is the range of valid values of a numeric configuration field.
+type configRange struct {
+	Min, Max     float64 // bounds, which may be infinite
+	ExclusiveMin bool    // whether the minimum itself is invalid
+}
+
+// contains returns whether the argument value is in the range.
+func (r configRange) contains(v float64) bool {
+	if v < r.Min || v > r.Max {
+		return false
+	}
+	return !r.ExclusiveMin || v > r.Min
+}
+
+func (r configRange) String() string {
+	lower := "["
+	if r.ExclusiveMin {
+		lower = "("
+	}
+	if math.IsInf(r.Max, 1) {
+		return fmt.Sprintf("%s%v, inf)", lower, r.Min)
+	}
+	return fmt.Sprintf("%s%v, %v]", lower, r.Min, r.Max)
+}
+
+// configRanges maps numeric configuration fields to their valid ranges; the
+// other numeric fields are unbounded.
+var configRanges = map[string]configRange{
+	"NumInputs":          {Min: 1, Max: math.Inf(1)},
+	"NumOutputs":         {Min: 1, Max: math.Inf(1)},
+	"NumInitHidden":      {Min: 0, Max: math.Inf(1)},
+	"PopulationSize":     {Min: 1, Max: math.Inf(1)},
+	"NumTournaments":     {Min: 0, Max: math.Inf(1)},
+	"MutAddNodeRate":     {Min: 0, Max: 1},
+	"MutAddEdgeRate":     {Min: 0, Max: 1},
+	"CrossoverRate":      {Min: 0, Max: 1},
+	"NumGenerations":     {Min: 0, Max: math.Inf(1)},
+	"NumEpochs":          {Min: 0, Max: math.Inf(1)},
+	"BatchSize":          {Min: 1, Max: math.Inf(1)},
+	"LearningRate":       {Min: 0, Max: math.Inf(1), ExclusiveMin: true},
+	"Width":              {Min: 1, Max: math.Inf(1)},
+	"Height":             {Min: 1, Max: math.Inf(1)},
+	"NoveltyK":           {Min: 1, Max: math.Inf(1)},
+	"NoveltyArchiveSize": {Min: 1, Max: math.Inf(1)},
+	"NoveltyResolution":  {Min: 1, Max: math.Inf(1)},
+	"NoveltyBlend":       {Min: 0, Max: 1},
+	"RenderSamples":      {Min: 0, Max: math.Inf(1)},
+	"RenderZoom":         {Min: 0, Max: math.Inf(1)},
+}
+
+// Validate checks that numeric fields are in their ranges, that there are
+// enough inputs for the input encoding, that the final render options are
+// valid, and that the camera path is sorted. The warp genome is not loaded
+// until rendering. It returns a *ConfigError listing the problems, if any.
+func (c *Configuration) Validate() error {
+	problems := make([]string, 0)
+
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		r, ok := configRanges[name]
+		if !ok {
+			continue
+		}
+		var x float64
+		switch f := v.Field(i); f.Kind() {
+		case reflect.Int, reflect.Int64:
+			x = float64(f.Int())
+		case reflect.Float64:
+			x = f.Float()
+		}
+		if !r.contains(x) {
+			problems = append(problems,
+				fmt.Sprintf("%s is %v, not in %s", name, x, r))
+		}
+	}
+
+	if n := numEncodedInputs(c.InputEncoding); c.NumInputs < n {
+		problems = append(problems, fmt.Sprintf("NumInputs is %d, fewer "+
+			"than the %d inputs of the input encoding", c.NumInputs, n))
+	}
+	if _, err := c.finalRenderOptions(1, 1); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if err := c.RenderPath.Validate(); err != nil {
+		problems = append(problems, err.Error())
+	}
 
-	var config Configuration
-	if err := dec.Decode(&config); err != nil {
-		if err != io.EOF {
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
+
+// validateConfig checks configuration files.
+func validateConfig(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: imagen validate-config [config].json ...")
+	}
+
+	numInvalid := 0
+	for _, filename := range args {
+		if _, err := NewConfiguration(filename); err != nil {
+			fmt.Println(err)
+			numInvalid++
+		}
+	}
+	if numInvalid > 0 {
+		return fmt.Errorf("%d of %d configurations are invalid", numInvalid,
+			len(args))
+	}
+	return nil
+}
+
+// RenderOptions returns render options of the argument size that render
+// genomes as they are trained, with the configured input encoding.
+func (c *Configuration) RenderOptions(width, height int) *RenderOptions {
+	opts := NewRenderOptions(width, height)
+	if c.InputEncoding != "" {
+		opts.Encoding = c.InputEncoding
+	}
+	// an invalid symmetry or color space is rejected by FinalRenderOptions
+	opts.Symmetry, _ = ParseSymmetry(c.Symmetry)
+	opts.ColorSpace, _ = ParseColorSpace(c.ColorSpace, c.Palette)
+	return opts
+}
+
+// validateOutputs returns an error if the configured number of outputs does
+// not fit the color space, before any genome is trained.
+func (c *Configuration) validateOutputs() error {
+	return c.RenderOptions(1, 1).ColorSpace.Validate(c.NumOutputs)
+}
+
+// inherit sets the input encoding, symmetry and color space that are not
+// configured to those the genome of the argument metadata was trained with,
+// so that it is rendered as trained.
+func (c *Configuration) inherit(meta *GenomeMeta) {
+	if c.InputEncoding == "" {
+		c.InputEncoding = meta.InputEncoding
+	}
+	if c.ColorSpace == "" {
+		c.ColorSpace = meta.ColorSpace
+	}
+	if meta.Config != nil {
+		if c.Symmetry == "" {
+			c.Symmetry = meta.Config.Symmetry
+		}
+		if len(c.Palette) == 0 {
+			c.Palette = meta.Config.Palette
+		}
+	}
+}
+
+// extractPalette extracts the palette of palette outputs from the argument
+// target image, if the color space is palette and no palette is configured.
+// The palette has a color for each output, and is printed, so that it can be
+// configured to render the trained genomes later.
+func (c *Configuration) extractPalette(img image.Image) {
+	if c.ColorSpace != "palette" || len(c.Palette) > 0 {
+		return
+	}
+	for _, p := range ExtractPalette(img, c.NumOutputs) {
+		c.Palette = append(c.Palette, hexColor(p))
+	}
+	fmt.Printf("Palette: %s\n", strings.Join(c.Palette, " "))
+}
+
+// FinalRenderOptions returns render options of the argument size for final
+// renders, with the configured supersampling, viewport and domain warp.
+// Training is not affected by these options. It returns an error if the
+// options are invalid, or if the warp genome cannot be loaded.
+func (c *Configuration) FinalRenderOptions(width,
+	height int) (*RenderOptions, error) {
+	opts, err := c.finalRenderOptions(width, height)
+	if err != nil {
+		return nil, err
+	}
+	if c.RenderWarp != "" {
+		warp, err := LoadGenome(c.RenderWarp, 0)
+		if err != nil {
 			return nil, err
 		}
+		opts.Warp = warp
+		if err := opts.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return opts, nil
+}
+
+// finalRenderOptions returns the final render options as in
+// FinalRenderOptions, without the domain warp, so that they can be validated
+// without loading the warp genome.
+func (c *Configuration) finalRenderOptions(width,
+	height int) (*RenderOptions, error) {
+	if _, err := ParseSymmetry(c.Symmetry); err != nil {
+		return nil, err
+	}
+	if _, err := ParseColorSpace(c.ColorSpace, c.Palette); err != nil {
+		return nil, err
+	}
+	opts := c.RenderOptions(width, height)
+	if c.RenderSamples > 0 {
+		opts.Samples = c.RenderSamples
+	}
+	opts.Jitter = c.RenderJitter
+	if c.RenderFilter != "" {
+		opts.Filter = c.RenderFilter
+	}
+	if c.RenderDepth != 0 {
+		opts.BitDepth = c.RenderDepth
 	}
 
-	return &config, nil
+	if c.RenderZoom != 0.0 {
+		opts.Zoom = c.RenderZoom
+	}
+	opts.PanX, opts.PanY = c.RenderPanX, c.RenderPanY
+	opts.Rotation = c.RenderRotation
+	if c.RenderWarpStrength != 0.0 {
+		opts.WarpStrength = c.RenderWarpStrength
+	}
+
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	return opts, nil
 }
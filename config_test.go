@@ -0,0 +1,172 @@
+/*
+
+
+config_test.go tests for configurations.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewConfiguration(t *testing.T) {
+	// the example configurations are valid
+	filenames, err := filepath.Glob(filepath.Join("tests", "*", "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, filename := range filenames {
+		if _, err := NewConfiguration(filename); err != nil {
+			t.Error(err)
+		}
+	}
+
+	dir := t.TempDir()
+	write := func(content string) string {
+		filename := filepath.Join(dir, "config.json")
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return filename
+	}
+
+	// omitted fields are set to their defaults
+	config, err := NewConfiguration(write(`{"Seed": 3, "NumOutputs": 4}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := DefaultConfiguration()
+	expected.Seed, expected.NumOutputs = 3, 4
+	if a, b := marshal(t, config), marshal(t, expected); !bytes.Equal(a, b) {
+		t.Errorf("configuration is %s, expected %s", a, b)
+	}
+
+	for _, c := range []struct {
+		content string
+		problem string
+	}{
+		{"", "empty"},
+		{`{"LearningRat": 0.1}`, "LearningRat"},
+		{`{"Seed": 1} {"Seed": 2}`, "after"},
+		{`{"PopulationSize": 0}`, "PopulationSize"},
+		{`{"LearningRate": 0}`, "LearningRate"},
+		{`{"CrossoverRate": 1.5}`, "CrossoverRate"},
+		{`{"ColorSpace": "cmyk"}`, "cmyk"},
+		{`{"InputEncoding": "torus"}`, "NumInputs"},
+		{`{"NumInputs": 3}`, "NumInputs"},
+		{`{"RenderPath": [{"Frame": 1, "Zoom": 1}, {"Frame": 0, "Zoom": 1}]}`,
+			"keyframe"},
+	} {
+		_, err := NewConfiguration(write(c.content))
+		if err == nil || !strings.Contains(err.Error(), c.problem) {
+			t.Errorf("configuration %q has error %v, expected %q", c.content,
+				err, c.problem)
+		}
+	}
+
+	// the warp genome is only loaded to render
+	config, err = NewConfiguration(write(`{"RenderWarp": "missing.txt"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.FinalRenderOptions(8, 8); err == nil {
+		t.Error("missing warp genome is loaded")
+	}
+
+	// all problems are listed
+	config = DefaultConfiguration()
+	config.BatchSize, config.MutAddNodeRate = -1, -0.5
+	err = config.Validate()
+	if e, ok := err.(*ConfigError); !ok || len(e.Problems) != 2 {
+		t.Errorf("configuration has error %v, expected 2 problems", err)
+	}
+}
+
+func TestConfigurationSchema(t *testing.T) {
+	s := ConfigurationSchema()
+	properties := s["properties"].(map[string]interface{})
+	if len(properties) != len(marshalFields(t, DefaultConfiguration())) {
+		t.Errorf("schema has %d properties, expected every field",
+			len(properties))
+	}
+	rate := properties["CrossoverRate"].(map[string]interface{})
+	if rate["type"] != "number" || rate["minimum"] != 0.0 ||
+		rate["maximum"] != 1.0 || rate["default"] != 0.2 {
+		t.Errorf("CrossoverRate is %v in the schema", rate)
+	}
+	path := properties["RenderPath"].(map[string]interface{})
+	items := path["items"].(map[string]interface{})
+	frame := items["properties"].(map[string]interface{})["Frame"]
+	if frame.(map[string]interface{})["type"] != "integer" {
+		t.Errorf("RenderPath is %v in the schema", path)
+	}
+
+	// the schema in the repository is up to date
+	data, err := marshalSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.ReadFile("config.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, file) {
+		t.Error("config.schema.json is out of date, " +
+			"run imagen schema config.schema.json")
+	}
+}
+
+// marshal returns the JSON of the argument value.
+func marshal(t *testing.T, v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// marshalFields returns the fields of the argument value in JSON, including
+// those that are omitted when empty.
+func marshalFields(t *testing.T, config *Configuration) map[string]interface{} {
+	config.Schema = "schema"
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(marshal(t, config), &fields); err != nil {
+		t.Fatal(err)
+	}
+	return fields
+}
@@ -90,14 +90,17 @@ func (n *Node) Activate() *mat64.Vector {
 		return n.Signal
 	}
 
-	// accumulate input signals
-	n.Signal = mat64.NewVector(n.Signal.Len(), nil)
+	// accumulate input signals, reusing this node's signal vector
+	signal := n.Signal.RawVector().Data
+	for i := range signal {
+		signal[i] = 0.0
+	}
 	for node, weight := range n.Inputs {
 		n.Signal.AddScaledVec(n.Signal, weight, node.Activate())
 	}
 
-	for i := 0; i < n.Signal.Len(); i++ {
-		n.Signal.SetVec(i, n.AFunc.Fn(n.Signal.At(i, 0)))
+	for i := range signal {
+		signal[i] = n.AFunc.Fn(signal[i])
 	}
 	return n.Signal
 }
@@ -36,11 +36,18 @@ for the Go code in this page.
 package main
 
 import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"math"
 	"math/rand"
 	"os"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -135,6 +142,103 @@ func NewGenome(id, numInputs, numHidden, numOutputs int) *Genome {
 	}
 }
 
+// Clone returns a deep copy of the genome, whose edges reference the copies
+// of its nodes. Modifying the copy does not affect the original.
+func (g *Genome) Clone() *Genome {
+	nodes := make(map[*NodeGene]*NodeGene, len(g.NodeGenes))
+	clone := func(node *NodeGene) *NodeGene {
+		if c, ok := nodes[node]; ok {
+			return c
+		}
+		c := NewNodeGene(node.ID, node.Type, node.AFuncType)
+		nodes[node] = c
+		return c
+	}
+
+	nodeCopies := make([]*NodeGene, len(g.NodeGenes))
+	for i, node := range g.NodeGenes {
+		nodeCopies[i] = clone(node)
+	}
+	edgeCopies := make([]*EdgeGene, len(g.EdgeGenes))
+	for i, edge := range g.EdgeGenes {
+		edgeCopies[i] = &EdgeGene{
+			InputNode:  clone(edge.InputNode),
+			OutputNode: clone(edge.OutputNode),
+			Weight:     edge.Weight,
+		}
+	}
+
+	return &Genome{
+		ID:         g.ID,
+		NumInputs:  g.NumInputs,
+		NumOutputs: g.NumOutputs,
+		NumHidden:  g.NumHidden,
+		NodeGenes:  nodeCopies,
+		EdgeGenes:  edgeCopies,
+		Fitness:    g.Fitness,
+	}
+}
+
+// canonical returns the genome's nodes and edges as strings in a canonical
+// order, so that genomes that differ only in the order of their genes have
+// the same representation. Weights are represented exactly.
+func (g *Genome) canonical() ([]string, []string) {
+	nodes := make([]string, len(g.NodeGenes))
+	for i, node := range g.NodeGenes {
+		nodes[i] = fmt.Sprintf("%d %s %s", node.ID, node.Type, node.AFuncType)
+	}
+	edges := make([]string, len(g.EdgeGenes))
+	for i, edge := range g.EdgeGenes {
+		edges[i] = fmt.Sprintf("%d %d %x", edge.InputNode.ID,
+			edge.OutputNode.ID, math.Float64bits(edge.Weight))
+	}
+	sort.Strings(nodes)
+	sort.Strings(edges)
+	return nodes, edges
+}
+
+// Equal returns true if the argument genome has the same structure as this
+// genome: the same numbers of inputs, outputs and hidden nodes, the same
+// nodes, and the same edges with the same weights, regardless of their
+// order. Genome IDs and fitness scores are not compared.
+func (g *Genome) Equal(g0 *Genome) bool {
+	if g.NumInputs != g0.NumInputs || g.NumOutputs != g0.NumOutputs ||
+		g.NumHidden != g0.NumHidden ||
+		len(g.NodeGenes) != len(g0.NodeGenes) ||
+		len(g.EdgeGenes) != len(g0.EdgeGenes) {
+		return false
+	}
+
+	nodes, edges := g.canonical()
+	nodes0, edges0 := g0.canonical()
+	for i := range nodes {
+		if nodes[i] != nodes0[i] {
+			return false
+		}
+	}
+	for i := range edges {
+		if edges[i] != edges0[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Hash returns a canonical hash of the genome's structure, which is the same
+// for any two genomes that are Equal.
+func (g *Genome) Hash() string {
+	h := sha256.New()
+	nodes, edges := g.canonical()
+	fmt.Fprintf(h, "%d %d %d\n", g.NumInputs, g.NumOutputs, g.NumHidden)
+	for _, node := range nodes {
+		fmt.Fprintf(h, "n %s\n", node)
+	}
+	for _, edge := range edges {
+		fmt.Fprintf(h, "e %s\n", edge)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 // ToString summarizes the genome's connectivity in a string.
 func (g *Genome) ToString() string {
 	str := fmt.Sprintf("Genome(%d):\n", g.ID)
@@ -174,6 +278,7 @@ func (g *Genome) ToString() string {
 	return str
 }
 
+// Export exports the genome's node and edge data to a text file.
 func (g *Genome) Export() error {
 	// genome_[id]_[exported time].txt
 	filename := fmt.Sprintf("genome_%d_%d.txt", g.ID, time.Now().UnixNano())
@@ -181,11 +286,18 @@ func (g *Genome) Export() error {
 	if err != nil {
 		return err
 	}
+	defer f.Close()
+
+	return g.ExportTo(f)
+}
 
+// ExportTo writes the genome's node and edge data to the argument writer, in
+// the same format as Export.
+func (g *Genome) ExportTo(w io.Writer) error {
 	// node data
 	for _, node := range g.NodeGenes {
 		dat := fmt.Sprintf("n %d %s %s", node.ID, node.Type, node.AFuncType)
-		_, err := f.WriteString(dat + "\n")
+		_, err := io.WriteString(w, dat+"\n")
 		if err != nil {
 			return err
 		}
@@ -195,7 +307,7 @@ func (g *Genome) Export() error {
 	for _, edge := range g.EdgeGenes {
 		dat := fmt.Sprintf("e %d %d %f",
 			edge.InputNode.ID, edge.OutputNode.ID, edge.Weight)
-		_, err := f.WriteString(dat + "\n")
+		_, err := io.WriteString(w, dat+"\n")
 		if err != nil {
 			return err
 		}
@@ -204,6 +316,91 @@ func (g *Genome) Export() error {
 	return nil
 }
 
+// ImportGenome reads a genome in the format written by Export from the
+// argument reader, and assigns it the argument ID. It returns an error if the
+// data is malformed, or if an edge connects nodes that do not exist.
+func ImportGenome(r io.Reader, id int) (*Genome, error) {
+	g := &Genome{
+		ID:        id,
+		NodeGenes: make([]*NodeGene, 0),
+		EdgeGenes: make([]*EdgeGene, 0),
+	}
+	nodes := make(map[int]*NodeGene)
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch {
+		case fields[0] == "n" && len(fields) == 4:
+			nid, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", line, err)
+			}
+			if _, ok := nodes[nid]; ok {
+				return nil, fmt.Errorf("line %d: duplicate node %d", line, nid)
+			}
+			node := NewNodeGene(nid, fields[2], fields[3])
+			nodes[nid] = node
+			g.NodeGenes = append(g.NodeGenes, node)
+
+			switch node.Type {
+			case "input":
+				g.NumInputs++
+			case "output":
+				g.NumOutputs++
+			default:
+				g.NumHidden++
+			}
+
+		case fields[0] == "e" && len(fields) == 4:
+			from, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", line, err)
+			}
+			to, err := strconv.Atoi(fields[2])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", line, err)
+			}
+			weight, err := strconv.ParseFloat(fields[3], 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", line, err)
+			}
+			if nodes[from] == nil || nodes[to] == nil {
+				return nil, fmt.Errorf("line %d: edge between unknown "+
+					"nodes %d and %d", line, from, to)
+			}
+			g.EdgeGenes = append(g.EdgeGenes, &EdgeGene{
+				InputNode:  nodes[from],
+				OutputNode: nodes[to],
+				Weight:     weight,
+			})
+
+		default:
+			return nil, fmt.Errorf("line %d: invalid genome data", line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// LoadGenome imports a genome from the argument file, written by Export or
+// ExportGenome, and assigns it the argument ID. Its metadata is discarded.
+func LoadGenome(filename string, id int) (*Genome, error) {
+	g, _, err := OpenGenome(filename)
+	if err != nil {
+		return nil, err
+	}
+	g.ID = id
+	return g, nil
+}
+
 // pathSearch checks if there is a path from the start node to the goal node
 // in the genome, and therefore the network is recurrent, when the start node
 // is connected from the goal node.
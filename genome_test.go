@@ -257,3 +257,113 @@ func TestGenome(t *testing.T) {
 	// black box test for Genome
 	GenomeAcceptanceTest()
 }
+
+func TestGenomeValidate(t *testing.T) {
+	rand.Seed(0)
+
+	g := NewGenome(0, 3, 4, 2)
+	for i := 0; i < 10; i++ {
+		g.Mutate(0.5, 0.5)
+	}
+	if err := g.Validate(); err != nil {
+		t.Errorf("mutated genome should be valid: %s", err)
+	}
+
+	// each of the following breaks one invariant of a new genome
+	cases := map[string]func(g *Genome){
+		"duplicate node ID": func(g *Genome) {
+			g.NodeGenes[len(g.NodeGenes)-1].ID = g.NodeGenes[0].ID
+		},
+		"unknown node": func(g *Genome) {
+			g.EdgeGenes[0].InputNode = NewNodeGene(99, "hidden", "identity")
+		},
+		"duplicate edge": func(g *Genome) {
+			edge := *g.EdgeGenes[0]
+			g.EdgeGenes = append(g.EdgeGenes, &edge)
+		},
+		"edge into input": func(g *Genome) {
+			g.EdgeGenes = append(g.EdgeGenes,
+				NewEdgeGene(g.NodeGenes[5], g.NodeGenes[0]))
+		},
+		"cycle": func(g *Genome) {
+			g.EdgeGenes = append(g.EdgeGenes,
+				NewEdgeGene(g.NodeGenes[3], g.NodeGenes[5]))
+		},
+		"NumHidden": func(g *Genome) {
+			g.NumHidden++
+		},
+		"unreachable output": func(g *Genome) {
+			edges := make([]*EdgeGene, 0)
+			for _, edge := range g.EdgeGenes {
+				if edge.OutputNode.ID != 3 {
+					edges = append(edges, edge)
+				}
+			}
+			g.EdgeGenes = edges
+		},
+	}
+	for name, corrupt := range cases {
+		g := NewGenome(0, 3, 4, 2)
+		corrupt(g)
+		if err := g.Validate(); err == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+}
+
+func TestGenomeClone(t *testing.T) {
+	rand.Seed(0)
+
+	g0 := NewGenome(0, 3, 4, 2)
+	g1 := NewGenome(1, 3, 4, 2)
+	for i := 0; i < 10; i++ {
+		g0.Mutate(0.5, 0.5)
+		g1.Mutate(0.5, 0.5)
+	}
+	g0.Crossover(g1)
+
+	clone := g0.Clone()
+	if !clone.Equal(g0) || clone.Hash() != g0.Hash() {
+		t.Fatal("clone should be equal to the original")
+	}
+
+	// the clone must not share any genes with the original
+	genes := make(map[interface{}]bool)
+	for _, node := range g0.NodeGenes {
+		genes[node] = true
+	}
+	for _, edge := range g0.EdgeGenes {
+		genes[edge] = true
+		genes[edge.InputNode] = true
+		genes[edge.OutputNode] = true
+	}
+	for _, edge := range clone.EdgeGenes {
+		if genes[edge] || genes[edge.InputNode] || genes[edge.OutputNode] {
+			t.Fatal("clone shares genes with the original")
+		}
+	}
+
+	// reordering genes does not change the structure
+	reordered := g0.Clone()
+	rand.Shuffle(len(reordered.NodeGenes), func(i, j int) {
+		reordered.NodeGenes[i], reordered.NodeGenes[j] =
+			reordered.NodeGenes[j], reordered.NodeGenes[i]
+	})
+	rand.Shuffle(len(reordered.EdgeGenes), func(i, j int) {
+		reordered.EdgeGenes[i], reordered.EdgeGenes[j] =
+			reordered.EdgeGenes[j], reordered.EdgeGenes[i]
+	})
+	if !reordered.Equal(g0) || reordered.Hash() != g0.Hash() {
+		t.Error("reordered genome should be equal to the original")
+	}
+
+	// modifying the clone does not change the original
+	clone.EdgeGenes[0].Weight += 1.0
+	clone.AddNode()
+	if clone.Equal(g0) || clone.Hash() == g0.Hash() {
+		t.Error("modified clone should not be equal to the original")
+	}
+	if !reordered.Equal(g0) {
+		t.Error("original changed after modifying its clone")
+	}
+}
@@ -0,0 +1,427 @@
+/*
+
+
+graph.go implementation of DOT and SVG export of genomes and DPPNs.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// graphNode is a node in a netGraph.
+type graphNode struct {
+	ID    int    // node ID
+	Type  string // node type
+	AFunc string // name of activation function
+}
+
+// graphEdge is a directed edge in a netGraph.
+type graphEdge struct {
+	From   int     // ID of the input node
+	To     int     // ID of the output node
+	Weight float64 // connection weight
+}
+
+// netGraph is a plain view of the nodes and edges of a genome or a DPPN, on
+// which structural analyses are performed.
+type netGraph struct {
+	Name  string      // name of the graph
+	Nodes []graphNode // nodes, sorted by ID
+	Edges []graphEdge // edges
+}
+
+// newGenomeGraph creates a graph of the argument genome's nodes and edges.
+func newGenomeGraph(g *Genome) *netGraph {
+	gr := &netGraph{Name: fmt.Sprintf("Genome%d", g.ID)}
+	for _, node := range g.NodeGenes {
+		gr.Nodes = append(gr.Nodes, graphNode{node.ID, node.Type, node.AFuncType})
+	}
+	for _, edge := range g.EdgeGenes {
+		gr.Edges = append(gr.Edges, graphEdge{edge.InputNode.ID,
+			edge.OutputNode.ID, edge.Weight})
+	}
+	sort.Slice(gr.Nodes, func(i, j int) bool {
+		return gr.Nodes[i].ID < gr.Nodes[j].ID
+	})
+	return gr
+}
+
+// newDPPNGraph creates a graph of the argument DPPN's nodes and connections.
+func newDPPNGraph(d *DPPN) *netGraph {
+	gr := &netGraph{Name: fmt.Sprintf("DPPN%d", d.ID)}
+	for _, node := range d.Nodes {
+		gr.Nodes = append(gr.Nodes, graphNode{node.ID, node.Type, node.AFunc.Name})
+		for input, weight := range node.Inputs {
+			gr.Edges = append(gr.Edges, graphEdge{input.ID, node.ID, weight})
+		}
+	}
+	sort.Slice(gr.Edges, func(i, j int) bool {
+		if gr.Edges[i].From != gr.Edges[j].From {
+			return gr.Edges[i].From < gr.Edges[j].From
+		}
+		return gr.Edges[i].To < gr.Edges[j].To
+	})
+	return gr
+}
+
+// reachable returns the set of IDs of nodes that can be reached from nodes of
+// the argument type, following edges forward, or backward if reverse is
+// true.
+func (gr *netGraph) reachable(nodeType string, reverse bool) map[int]bool {
+	adj := make(map[int][]int)
+	for _, e := range gr.Edges {
+		if reverse {
+			adj[e.To] = append(adj[e.To], e.From)
+		} else {
+			adj[e.From] = append(adj[e.From], e.To)
+		}
+	}
+
+	visited := make(map[int]bool)
+	stack := make([]int, 0)
+	for _, n := range gr.Nodes {
+		if n.Type == nodeType {
+			visited[n.ID] = true
+			stack = append(stack, n.ID)
+		}
+	}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, next := range adj[id] {
+			if !visited[next] {
+				visited[next] = true
+				stack = append(stack, next)
+			}
+		}
+	}
+	return visited
+}
+
+// dead returns the set of IDs of hidden nodes that do not lie on any path
+// from an input node to an output node, and therefore do not contribute to
+// the outputs.
+func (gr *netGraph) dead() map[int]bool {
+	fromInputs := gr.reachable("input", false)
+	toOutputs := gr.reachable("output", true)
+
+	dead := make(map[int]bool)
+	for _, n := range gr.Nodes {
+		if n.Type == "hidden" && !(fromInputs[n.ID] && toOutputs[n.ID]) {
+			dead[n.ID] = true
+		}
+	}
+	return dead
+}
+
+// layers assigns each node the length of the longest path to it from a node
+// without inputs. Output nodes are placed in the last layer. Nodes on a
+// cycle, if any, are placed in the first layer.
+func (gr *netGraph) layers() map[int]int {
+	indegree := make(map[int]int)
+	adj := make(map[int][]int)
+	for _, e := range gr.Edges {
+		adj[e.From] = append(adj[e.From], e.To)
+		indegree[e.To]++
+	}
+
+	layer := make(map[int]int)
+	queue := make([]int, 0)
+	for _, n := range gr.Nodes {
+		layer[n.ID] = 0
+		if indegree[n.ID] == 0 {
+			queue = append(queue, n.ID)
+		}
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, next := range adj[id] {
+			if layer[id]+1 > layer[next] {
+				layer[next] = layer[id] + 1
+			}
+			indegree[next]--
+			if indegree[next] == 0 {
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	last := 0
+	for _, n := range gr.Nodes {
+		if layer[n.ID] > last {
+			last = layer[n.ID]
+		}
+	}
+	for _, n := range gr.Nodes {
+		if n.Type == "output" {
+			layer[n.ID] = last
+		}
+	}
+	return layer
+}
+
+// maxWeight returns the largest finite absolute connection weight in the
+// graph, or 1.0 if there are none.
+func (gr *netGraph) maxWeight() float64 {
+	max := 0.0
+	for _, e := range gr.Edges {
+		if !math.IsNaN(e.Weight) && !math.IsInf(e.Weight, 0) {
+			max = math.Max(max, math.Abs(e.Weight))
+		}
+	}
+	if max == 0.0 {
+		return 1.0
+	}
+	return max
+}
+
+// nodeColors maps each node type to its fill color.
+var nodeColors = map[string]string{
+	"input":  "#9ecae1",
+	"hidden": "#d9d9d9",
+	"output": "#fc9272",
+}
+
+// edgeStyle returns the color and the width of an edge with the argument
+// weight, given the largest absolute weight in the graph. Weights that are
+// not finite are drawn thin and in a color of their own.
+func edgeStyle(weight, max float64) (string, float64) {
+	if math.IsNaN(weight) || math.IsInf(weight, 0) {
+		return "#ff7f00", 0.5
+	}
+	color := "#2171b5" // positive weights
+	if weight < 0.0 {
+		color = "#cb181d" // negative weights
+	}
+	return color, 0.5 + 3.5*math.Min(1.0, math.Abs(weight)/max)
+}
+
+// DOT returns the graph in the Graphviz DOT language. Nodes are colored by
+// type and labeled with their activation function, edges are colored by the
+// sign of their weight and scaled by its magnitude, and dead nodes are
+// highlighted.
+func (gr *netGraph) DOT() string {
+	var b strings.Builder
+	dead := gr.dead()
+	max := gr.maxWeight()
+
+	fmt.Fprintf(&b, "digraph %s {\n", gr.Name)
+	b.WriteString("\trankdir=LR;\n")
+	b.WriteString("\tnode [shape=circle, style=filled, fontsize=10];\n")
+	for _, n := range gr.Nodes {
+		attrs := fmt.Sprintf("label=\"%d\\n%s\", fillcolor=\"%s\"",
+			n.ID, n.AFunc, nodeColors[n.Type])
+		if dead[n.ID] {
+			attrs += ", style=\"filled,dashed\", color=\"#ff7f00\", penwidth=2"
+		}
+		fmt.Fprintf(&b, "\t%d [%s];\n", n.ID, attrs)
+	}
+	for _, e := range gr.Edges {
+		color, width := edgeStyle(e.Weight, max)
+		fmt.Fprintf(&b, "\t%d -> %d [color=\"%s\", penwidth=%.2f, "+
+			"tooltip=\"%f\"];\n", e.From, e.To, color, width, e.Weight)
+	}
+
+	// keep input and output nodes aligned
+	for _, nodeType := range []string{"input", "output"} {
+		ids := make([]string, 0)
+		for _, n := range gr.Nodes {
+			if n.Type == nodeType {
+				ids = append(ids, fmt.Sprint(n.ID))
+			}
+		}
+		if len(ids) > 0 {
+			fmt.Fprintf(&b, "\t{ rank=same; %s; }\n", strings.Join(ids, "; "))
+		}
+	}
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+// SVG lays out the graph in layers from inputs to outputs, and returns it as
+// an SVG image, styled in the same way as DOT. It does not need Graphviz.
+func (gr *netGraph) SVG() string {
+	const (
+		radius  = 18.0  // node radius
+		spacing = 140.0 // horizontal spacing between layers
+		gap     = 56.0  // vertical spacing between nodes in a layer
+		margin  = 40.0  // margin around the graph
+	)
+
+	layer := gr.layers()
+	numLayers := 0
+	for _, l := range layer {
+		if l+1 > numLayers {
+			numLayers = l + 1
+		}
+	}
+
+	// position each node in its layer; nodes are ordered by the mean
+	// position of their inputs to reduce edge crossings.
+	byLayer := make([][]int, numLayers)
+	for _, n := range gr.Nodes {
+		byLayer[layer[n.ID]] = append(byLayer[layer[n.ID]], n.ID)
+	}
+	inputs := make(map[int][]int)
+	for _, e := range gr.Edges {
+		inputs[e.To] = append(inputs[e.To], e.From)
+	}
+	x, y := make(map[int]float64), make(map[int]float64)
+	maxRows := 0
+	for l, ids := range byLayer {
+		if l > 0 {
+			mean := func(id int) float64 {
+				if len(inputs[id]) == 0 {
+					return 0.0
+				}
+				sum := 0.0
+				for _, in := range inputs[id] {
+					sum += y[in]
+				}
+				return sum / float64(len(inputs[id]))
+			}
+			sort.SliceStable(ids, func(i, j int) bool {
+				return mean(ids[i]) < mean(ids[j])
+			})
+		}
+		for i, id := range ids {
+			x[id] = margin + radius + float64(l)*spacing
+			y[id] = margin + radius + float64(i)*gap
+		}
+		if len(ids) > maxRows {
+			maxRows = len(ids)
+		}
+	}
+
+	width := 2*(margin+radius) + float64(numLayers-1)*spacing
+	height := 2*(margin+radius) + float64(maxRows-1)*gap
+	if maxRows == 0 {
+		height = 2 * margin
+	}
+
+	var b strings.Builder
+	dead := gr.dead()
+	max := gr.maxWeight()
+
+	fmt.Fprintf(&b, "<svg xmlns=\"http://www.w3.org/2000/svg\" "+
+		"width=\"%.0f\" height=\"%.0f\" font-family=\"sans-serif\" "+
+		"font-size=\"9\">\n", width, height)
+	fmt.Fprintf(&b, "<title>%s</title>\n", gr.Name)
+	for _, e := range gr.Edges {
+		color, w := edgeStyle(e.Weight, max)
+		fmt.Fprintf(&b, "<line x1=\"%.1f\" y1=\"%.1f\" x2=\"%.1f\" "+
+			"y2=\"%.1f\" stroke=\"%s\" stroke-width=\"%.2f\" "+
+			"stroke-opacity=\"0.7\"><title>%d -> %d (%f)</title></line>\n",
+			x[e.From], y[e.From], x[e.To], y[e.To], color, w,
+			e.From, e.To, e.Weight)
+	}
+	for _, n := range gr.Nodes {
+		stroke := "stroke=\"#525252\" stroke-width=\"1\""
+		if dead[n.ID] {
+			stroke = "stroke=\"#ff7f00\" stroke-width=\"2\" " +
+				"stroke-dasharray=\"4,2\""
+		}
+		fmt.Fprintf(&b, "<circle cx=\"%.1f\" cy=\"%.1f\" r=\"%.0f\" "+
+			"fill=\"%s\" %s/>\n", x[n.ID], y[n.ID], radius,
+			nodeColors[n.Type], stroke)
+		fmt.Fprintf(&b, "<text x=\"%.1f\" y=\"%.1f\" "+
+			"text-anchor=\"middle\">%d</text>\n", x[n.ID], y[n.ID]-1, n.ID)
+		fmt.Fprintf(&b, "<text x=\"%.1f\" y=\"%.1f\" "+
+			"text-anchor=\"middle\">%s</text>\n", x[n.ID], y[n.ID]+9, n.AFunc)
+	}
+	b.WriteString("</svg>\n")
+
+	return b.String()
+}
+
+// DOT returns the genome's graph in the Graphviz DOT language.
+func (g *Genome) DOT() string {
+	return newGenomeGraph(g).DOT()
+}
+
+// SVG returns the genome's graph laid out as an SVG image.
+func (g *Genome) SVG() string {
+	return newGenomeGraph(g).SVG()
+}
+
+// DOT returns the network's graph in the Graphviz DOT language.
+func (d *DPPN) DOT() string {
+	return newDPPNGraph(d).DOT()
+}
+
+// SVG returns the network's graph laid out as an SVG image.
+func (d *DPPN) SVG() string {
+	return newDPPNGraph(d).SVG()
+}
+
+// dot prints the graph of an exported genome in the Graphviz DOT language.
+func dot(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: imagen dot [genome].txt")
+	}
+
+	g, err := LoadGenome(args[0], 0)
+	if err != nil {
+		return err
+	}
+	fmt.Print(g.DOT())
+	return nil
+}
+
+// svg writes the graph of an exported genome as an SVG image.
+func svg(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: imagen svg [genome].txt [output].svg")
+	}
+
+	g, err := LoadGenome(args[0], 0)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(args[1])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.WriteString(g.SVG())
+	return err
+}
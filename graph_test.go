@@ -0,0 +1,161 @@
+/*
+
+
+graph_test.go tests for graph export.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"math"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// testGraphGenome returns a genome with an input, two hidden nodes on a path
+// to the output, and a dead hidden node that cannot reach the output.
+func testGraphGenome() *Genome {
+	nodes := []*NodeGene{
+		NewNodeGene(0, "input", "identity"),
+		NewNodeGene(1, "output", "sigmoid"),
+		NewNodeGene(2, "hidden", "tanh"),
+		NewNodeGene(3, "hidden", "sine"),
+		NewNodeGene(4, "hidden", "relu"),
+	}
+	edge := func(from, to int, weight float64) *EdgeGene {
+		return &EdgeGene{InputNode: nodes[from], OutputNode: nodes[to],
+			Weight: weight}
+	}
+	return &Genome{
+		ID:         5,
+		NumInputs:  1,
+		NumOutputs: 1,
+		NumHidden:  3,
+		NodeGenes:  nodes,
+		EdgeGenes: []*EdgeGene{edge(0, 2, 2.0), edge(2, 3, -1.0),
+			edge(3, 1, 0.5), edge(0, 4, 1.0)},
+	}
+}
+
+func TestGraphStructure(t *testing.T) {
+	gr := newGenomeGraph(testGraphGenome())
+
+	dead := gr.dead()
+	if len(dead) != 1 || !dead[4] {
+		t.Errorf("dead nodes are %v, expected 4", dead)
+	}
+
+	layers := gr.layers()
+	expected := map[int]int{0: 0, 2: 1, 3: 2, 4: 1, 1: 3}
+	for id, l := range expected {
+		if layers[id] != l {
+			t.Errorf("node %d is in layer %d, expected %d", id, layers[id], l)
+		}
+	}
+
+	if max := gr.maxWeight(); max != 2.0 {
+		t.Errorf("largest weight is %f, expected 2", max)
+	}
+}
+
+func TestGraphDOT(t *testing.T) {
+	g := testGraphGenome()
+	g.EdgeGenes[3].Weight = math.NaN()
+	dot := g.DOT()
+
+	for _, line := range []string{
+		"digraph Genome5 {",
+		"\t3 [label=\"3\\nsine\", fillcolor=\"#d9d9d9\"];",
+		"\t{ rank=same; 0; }",
+		"\t{ rank=same; 1; }",
+	} {
+		if !strings.Contains(dot, line+"\n") {
+			t.Errorf("DOT has no line %q:\n%s", line, dot)
+		}
+	}
+	if !strings.Contains(dot, "\t4 [label=\"4\\nrelu\", fillcolor=\"#d9d9d9\", "+
+		"style=\"filled,dashed\"") {
+		t.Errorf("dead node is not highlighted:\n%s", dot)
+	}
+
+	// every edge has a finite width, scaled by the largest finite weight
+	pattern := regexp.MustCompile(`\t(\d+) -> (\d+) ` +
+		`\[color="(#[0-9a-f]{6})", penwidth=([0-9.]+), tooltip="([^"]*)"\];`)
+	edges := pattern.FindAllStringSubmatch(dot, -1)
+	if len(edges) != 4 {
+		t.Fatalf("DOT has %d valid edges, expected 4:\n%s", len(edges), dot)
+	}
+	widths := map[string]string{"0 2": "4.00", "2 3": "2.25", "3 1": "1.38",
+		"0 4": "0.50"}
+	for _, e := range edges {
+		if w := widths[e[1]+" "+e[2]]; e[4] != w {
+			t.Errorf("edge %s -> %s has width %s, expected %s", e[1], e[2],
+				e[4], w)
+		}
+	}
+	if edges[1][3] != "#cb181d" || edges[0][3] != "#2171b5" {
+		t.Errorf("edges are not colored by the sign of their weights")
+	}
+}
+
+func TestGraphSVG(t *testing.T) {
+	g := testGraphGenome()
+	g.EdgeGenes[0].Weight = math.Inf(1)
+
+	// the SVG is well-formed XML, with a circle per node and a line per edge
+	counts := make(map[string]int)
+	dec := xml.NewDecoder(strings.NewReader(g.SVG()))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			counts[start.Name.Local]++
+			for _, attr := range start.Attr {
+				if strings.Contains(attr.Value, "NaN") ||
+					strings.Contains(attr.Value, "Inf") {
+					t.Errorf("%s has attribute %s=%s", start.Name.Local,
+						attr.Name.Local, attr.Value)
+				}
+			}
+		}
+	}
+	if counts["svg"] != 1 || counts["circle"] != 5 || counts["line"] != 4 {
+		t.Errorf("SVG has elements %v", counts)
+	}
+}
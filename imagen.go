@@ -36,6 +36,8 @@ for the Go code in this page.
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gonum/matrix/mat64"
 	"image"
@@ -50,30 +52,62 @@ func help() {
 	fmt.Println("Imagen (Image Generation via DPPN)")
 	fmt.Println("Copyright (c) 2017 by Jin Yeom")
 	fmt.Println("User Manual:")
-	fmt.Println("  imagen [filename].png [config].json")
+	fmt.Println("  imagen [filename].png [config].json [[result].json]")
+	fmt.Println("  imagen novelty [config].json [[filename].png]")
+	fmt.Println("  imagen pareto [filename].png [config].json")
+	fmt.Println("  imagen sweep [filename].png [sweep].json")
+	fmt.Println("  imagen serve [config].json [address]")
+	fmt.Println("  imagen api [address] [store]")
+	fmt.Println("  imagen ancestors [store] [id]")
+	fmt.Println("  imagen descendants [store] [id]")
+	fmt.Println("  imagen dot [genome].txt")
+	fmt.Println("  imagen svg [genome].txt [output].svg")
+	fmt.Println("  imagen inspect [genome].txt ...")
+	fmt.Println("  imagen simplify [genome] [output](.json|.bin|.txt) " +
+		"[tolerance]")
+	fmt.Println("  imagen validate [genome].txt ...")
+	fmt.Println("  imagen validate-config [config].json ...")
+	fmt.Println("  imagen schema [[output].json]")
+	fmt.Println("  imagen convert [input] [output](.json|.bin|.txt)")
+	fmt.Println("  imagen codegen [genome] [output](.frag|.glsl|.js|.go) " +
+		"[[config].json]")
+	fmt.Println("  imagen onnx [genome].txt [output].onnx")
+	fmt.Println("  imagen render [-domain [width]x[height]] [genome] " +
+		"[output](.png|.pfm|.tif|.tiff|.dzi) [width] [height] [[config].json]")
+	fmt.Println("  imagen animate [genome] [width] [height] [config].json")
 }
 
-func draw(g *Genome, width, height int) {
-	n, _ := NewDPPN(g, 1)
-	img := image.NewRGBA(image.Rect(0, 0, width, height))
-
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			fx := float64(x)
-			fy := float64(y)
-			d := math.Sqrt((fx-float64(width)/2.0)*(fx-float64(width)/2.0) +
-				(fy-float64(height)/2.0)*(fy-float64(height)/2.0))
-			inputs := []float64{fx * 0.1, fy * 0.1, d * 0.1, 1.0}
-			inputVec := mat64.NewDense(1, 4, inputs)
-
-			outputVec, _ := n.FeedForward(inputVec)
-			outputs := outputVec.RawMatrix().Data
-
-			c := color.RGBA{uint8(outputs[0] * 255.0), uint8(outputs[1] * 255.0),
-				uint8(outputs[2] * 255.0), 255}
-			img.Set(x, y, c)
-		}
+// commands maps each subcommand name to the function that runs it, given the
+// remaining command line arguments.
+var commands = map[string]func([]string) error{
+	"ancestors":       ancestors,
+	"animate":         animate,
+	"api":             api,
+	"codegen":         codegen,
+	"convert":         convert,
+	"descendants":     descendants,
+	"dot":             dot,
+	"inspect":         inspect,
+	"novelty":         novelty,
+	"onnx":            onnx,
+	"pareto":          pareto,
+	"render":          renderCommand,
+	"schema":          schema,
+	"serve":           serve,
+	"simplify":        simplify,
+	"sweep":           sweep,
+	"svg":             svg,
+	"validate":        validate,
+	"validate-config": validateConfig,
+}
+
+func draw(g *Genome, opts *RenderOptions) {
+	r, err := NewRenderer(g, 0)
+	if err != nil {
+		fmt.Println(err)
+		return
 	}
+	img := r.RenderImage(opts)
 
 	f1, err := os.Create(fmt.Sprintf("estimated_%d.png", g.ID))
 	if err != nil {
@@ -84,40 +118,79 @@ func draw(g *Genome, width, height int) {
 	png.Encode(f1, img)
 }
 
+// exportPopulation renders the argument genomes with the argument options,
+// and exports them as text, as well as in JSON with the metadata of the
+// argument training configuration.
+func exportPopulation(population []*Genome, config *Configuration,
+	opts *RenderOptions) error {
+	for _, genome := range population {
+		draw(genome, opts)
+		if err := genome.Export(); err != nil {
+			return err
+		}
+		if _, err := ExportGenome(genome, config); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // genImage returns an evaluation function for fitting the argument image's
-// pixel value distribution.
-func genImage(img *image.RGBA, numBatch, numEpochs int,
-	learningRate float64) EvaluationFunc {
+// pixel value distribution, with the input encoding, symmetry and color space
+// of the argument render options. The error is computed in the color space.
+func genImage(img *image.NRGBA, numBatch, numEpochs int,
+	learningRate float64, opts *RenderOptions) EvaluationFunc {
 	width, height := img.Bounds().Max.X-img.Bounds().Min.X,
 		img.Bounds().Max.Y-img.Bounds().Min.Y
 
 	return func(g *Genome) float64 {
 		n, _ := NewDPPN(g, numBatch)
+		numInputs := g.NumInputs
+		numOutputs := g.NumOutputs
+		_, _, numAux := opts.ColorSpace.Channels(numOutputs)
 		score := 0.0
 
 		for i := 0; i < numEpochs; i++ {
 			// process a random batch of inputs and target outputs
-			inputs := make([]float64, 0, 4*numBatch)
-			target := make([]float64, 0, numBatch)
+			inputs := make([]float64, numInputs*numBatch)
+			target := make([]float64, numOutputs*numBatch)
 			for j := 0; j < numBatch; j++ {
 				x := rand.Intn(width)
 				y := rand.Intn(height)
 
 				// input
-				fx := float64(x)
-				fy := float64(y)
-				d := math.Sqrt((fx-float64(width)/2.0)*(fx-float64(width)/2.0) +
-					(fy-float64(height)/2.0)*(fy-float64(height)/2.0))
-				inputs = append(inputs, fx*0.1, fy*0.1, d*0.1, 1.0)
+				fx, fy := opts.Symmetry.Fold(float64(x), float64(y),
+					gridCenter(float64(width), width),
+					gridCenter(float64(height), height))
+				encodeInputs(opts.Encoding, fx, fy,
+					float64(width), float64(height), 0.0, nil,
+					inputs[j*numInputs:(j+1)*numInputs])
 
 				// target
-				c := img.At(x, y).(color.RGBA)
-				target = append(target, float64(c.R)/255.0,
-					float64(c.G)/255.0, float64(c.B)/255.0)
+				c := img.NRGBAAt(x, y)
+				opts.ColorSpace.Encode(c,
+					target[j*numOutputs:(j+1)*numOutputs])
 			}
 
-			inputBatch := mat64.NewDense(numBatch, 4, inputs)
-			targetBatch := mat64.NewDense(numBatch, 3, target)
+			inputBatch := mat64.NewDense(numBatch, numInputs, inputs)
+			targetBatch := mat64.NewDense(numBatch, numOutputs, target)
+
+			// auxiliary outputs are their own targets, without error, and
+			// the targets of hue are the nearest to the outputs
+			if numAux > 0 || opts.ColorSpace.Circular(numOutputs) {
+				outputs, err := n.FeedForward(inputBatch)
+				if err != nil {
+					panic(err)
+				}
+				for j := 0; j < numBatch; j++ {
+					for k := numOutputs - numAux; k < numOutputs; k++ {
+						targetBatch.Set(j, k, outputs.At(j, k))
+					}
+					opts.ColorSpace.AlignHue(
+						target[j*numOutputs:(j+1)*numOutputs],
+						outputs.At(j, 0))
+				}
+			}
 
 			mse, err := n.Backprop(inputBatch, targetBatch, learningRate)
 			if err != nil {
@@ -133,8 +206,73 @@ func genImage(img *image.RGBA, numBatch, numEpochs int,
 	}
 }
 
+// loadImage decodes the argument PNG file, with colors that are not
+// premultiplied by alpha, whatever the color model of the file.
+func loadImage(filename string) (*image.NRGBA, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	if nrgba, ok := img.(*image.NRGBA); ok {
+		return nrgba, nil
+	}
+	bounds := img.Bounds()
+	nrgba := image.NewNRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			nrgba.Set(x, y, color.NRGBAModel.Convert(img.At(x, y)))
+		}
+	}
+	return nrgba, nil
+}
+
+// RunResult is the outcome of a training run, written as JSON by the
+// default command if a result file is given.
+type RunResult struct {
+	BestScore float64 // best score of the run
+	BestID    int     // ID of the best genome
+	NumNodes  int     // number of nodes of the best genome
+	NumEdges  int     // number of edges of the best genome
+}
+
+// writeResult writes the result of the argument run to the argument file
+// as JSON. It returns an error if the run has no best genome, which is the
+// case without tournaments, or if every score was NaN.
+func writeResult(filename string, env *MGA, bestScore float64) error {
+	best := env.Log.Best
+	if best == nil || len(best.NodeGenes) == 0 || math.IsNaN(bestScore) ||
+		math.IsInf(bestScore, 0) {
+		return errors.New("run has no best genome")
+	}
+	data, err := json.MarshalIndent(&RunResult{
+		BestScore: bestScore,
+		BestID:    best.ID,
+		NumNodes:  len(best.NodeGenes),
+		NumEdges:  len(best.EdgeGenes),
+	}, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
 func main() {
-	if len(os.Args) != 3 {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	if len(os.Args) != 3 && len(os.Args) != 4 {
 		help()
 		return
 	}
@@ -143,12 +281,7 @@ func main() {
 	configFile := os.Args[2]
 
 	// image file
-	f, err := os.Open(imgFile)
-	if err != nil {
-		panic(err)
-	}
-	defer f.Close()
-	img, err := png.Decode(f)
+	img, err := loadImage(imgFile)
 	if err != nil {
 		panic(err)
 	}
@@ -160,21 +293,38 @@ func main() {
 	}
 
 	rand.Seed(config.Seed)
+	config.extractPalette(img)
 
+	width, height := img.Bounds().Max.X-img.Bounds().Min.X,
+		img.Bounds().Max.Y-img.Bounds().Min.Y
+	config.Width, config.Height = width, height
 	env, err := NewMGA(config,
 		InverseComparison(),
-		genImage(img.(*image.RGBA), config.BatchSize,
-			config.NumEpochs, config.LearningRate))
+		genImage(img, config.BatchSize, config.NumEpochs,
+			config.LearningRate, config.RenderOptions(width, height)))
 	if err != nil {
 		panic(err)
 	}
-	env.Run(true, true)
+	if config.StorePath != "" {
+		if err := env.UseStore(config.StorePath); err != nil {
+			panic(err)
+		}
+		defer env.Store.Close()
+	}
+	bestScore := env.Run(true, true)
 
 	// export all the images and genomes in the population
-	width, height := img.Bounds().Max.X-img.Bounds().Min.X,
-		img.Bounds().Max.Y-img.Bounds().Min.Y
-	for _, genome := range env.Population {
-		draw(genome, width, height)
-		genome.Export()
+	opts, err := config.FinalRenderOptions(width, height)
+	if err != nil {
+		panic(err)
+	}
+	if err := exportPopulation(env.Population, config, opts); err != nil {
+		panic(err)
+	}
+
+	if len(os.Args) == 4 {
+		if err := writeResult(os.Args[3], env, bestScore); err != nil {
+			panic(err)
+		}
 	}
 }
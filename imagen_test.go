@@ -0,0 +1,221 @@
+/*
+
+
+imagen_test.go tests for image generation.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadImage(t *testing.T) {
+	dir := t.TempDir()
+	nrgba := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	nrgba.SetNRGBA(1, 2, color.NRGBA{200, 100, 50, 128})
+	gray := image.NewGray(image.Rect(0, 0, 4, 4))
+	gray.SetGray(1, 2, color.Gray{77})
+
+	for name, img := range map[string]image.Image{"nrgba.png": nrgba,
+		"gray.png": gray} {
+		filename := filepath.Join(dir, name)
+		f, err := os.Create(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := png.Encode(f, img); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		loaded, err := loadImage(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := color.NRGBAModel.Convert(img.At(1, 2))
+		if c := loaded.NRGBAAt(1, 2); c != expected {
+			t.Errorf("pixel of %s is %v, expected %v", name, c, expected)
+		}
+	}
+}
+
+func TestGenImageAlpha(t *testing.T) {
+	rand.Seed(0)
+
+	// opaque on the left, and transparent on the right
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{255, 0, 0, uint8(255 * (1 - x/4))})
+		}
+	}
+	opts := NewRenderOptions(8, 8)
+
+	g := NewGenome(0, 4, 4, 4)
+	eval := genImage(img, 16, 10, 0.1, opts)
+	if score := eval(g); math.IsNaN(score) || math.IsInf(score, 0) {
+		t.Errorf("score of alpha training is %f", score)
+	}
+}
+
+func TestGenImageOutputs(t *testing.T) {
+	rand.Seed(0)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(32 * x), uint8(32 * y), 0, 255})
+		}
+	}
+	opts := NewRenderOptions(8, 8)
+
+	// gray, RGB, and RGBA with auxiliary outputs
+	for _, numOutputs := range []int{1, 3, 6} {
+		g := NewGenome(0, 4, 4, numOutputs)
+		eval := genImage(img, 16, 10, 0.1, opts)
+		if score := eval(g); math.IsNaN(score) || math.IsInf(score, 0) {
+			t.Errorf("score of %d outputs is %f", numOutputs, score)
+		}
+		rendered := render(g, opts)
+		if c := rendered.RGBAAt(3, 4); numOutputs == 1 &&
+			(c.R != c.G || c.G != c.B) {
+			t.Errorf("pixel of a single output is %v, expected gray", c)
+		}
+	}
+}
+
+func TestExportPopulation(t *testing.T) {
+	rand.Seed(0)
+	t.Chdir(t.TempDir())
+
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(32 * x), uint8(32 * y), 0, 255})
+		}
+	}
+	config := DefaultConfiguration()
+	config.PopulationSize, config.NumTournaments = 2, 1
+	config.NumInputs, config.NumEpochs = 5, 1
+	config.InputEncoding, config.ColorSpace = "torus", "hsv"
+	opts := config.RenderOptions(8, 8)
+
+	env, err := NewMGA(config, InverseComparison(),
+		genImage(img, config.BatchSize, config.NumEpochs,
+			config.LearningRate, opts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.Run(false, false)
+	if err := exportPopulation(env.Population, config, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// the exported text is kept alongside the JSON
+	texts, err := filepath.Glob("genome_*.txt")
+	if err != nil || len(texts) != config.PopulationSize {
+		t.Fatalf("%d genomes exported as text: %v", len(texts), err)
+	}
+	filenames, err := filepath.Glob("genome_*.json")
+	if err != nil || len(filenames) != config.PopulationSize {
+		t.Fatalf("%d genomes exported: %v", len(filenames), err)
+	}
+	for _, filename := range filenames {
+		_, meta, err := OpenGenome(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reloaded := DefaultConfiguration()
+		reloaded.inherit(meta)
+		if reloaded.InputEncoding != "torus" || reloaded.ColorSpace != "hsv" {
+			t.Errorf("%s is rendered with %s inputs in %s", filename,
+				reloaded.InputEncoding, reloaded.ColorSpace)
+		}
+	}
+}
+
+func TestWriteResult(t *testing.T) {
+	rand.Seed(0)
+
+	config := DefaultConfiguration()
+	config.PopulationSize = 2
+	for _, c := range []struct {
+		numTournaments int
+		score          float64
+		ok             bool
+	}{
+		{0, 0.0, false},
+		{2, math.NaN(), false},
+		{2, 0.5, true},
+	} {
+		config.NumTournaments = c.numTournaments
+		env, err := NewMGA(config, InverseComparison(),
+			func(g *Genome) float64 { return c.score })
+		if err != nil {
+			t.Fatal(err)
+		}
+		bestScore := env.Run(false, false)
+
+		filename := filepath.Join(t.TempDir(), "result.json")
+		err = writeResult(filename, env, bestScore)
+		if !c.ok {
+			if err == nil {
+				t.Errorf("result of %d tournaments with score %f is written",
+					c.numTournaments, c.score)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result RunResult
+		if err := json.Unmarshal(data, &result); err != nil {
+			t.Fatal(err)
+		}
+		if result.BestScore != c.score || result.NumNodes == 0 {
+			t.Errorf("result is %+v", result)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+/*
+
+
+inspect.go implementation of structural analysis of genomes.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// GenomeStats contains the structural statistics of a genome.
+type GenomeStats struct {
+	NumNodes     int            // number of nodes
+	NumEdges     int            // number of edges
+	NodesByType  map[string]int // number of nodes of each type
+	EdgesByType  map[string]int // number of edges by the types they connect
+	AFuncs       map[string]int // number of hidden nodes by activation
+	Depth        int            // length of the longest path
+	LayerWidths  []int          // number of nodes in each layer
+	WeightMin    float64        // smallest connection weight
+	WeightMax    float64        // largest connection weight
+	WeightMean   float64        // mean connection weight
+	WeightStdDev float64        // standard deviation of connection weights
+	WeightHist   []int          // histogram of weights in WeightBins
+	NonFinite    int            // number of NaN or infinite weights
+	MaxFanIn     int            // largest number of inputs to a node
+	MaxFanOut    int            // largest number of outputs from a node
+	MeanFanIn    float64        // mean number of inputs to non-input nodes
+	MeanFanOut   float64        // mean number of outputs from non-output nodes
+	DeadNodes    []int          // hidden nodes that cannot affect outputs
+	UnusedInputs []int          // input nodes without a path to an output
+	Problems     []string       // invalid structure and non-finite weights
+}
+
+// WeightBins are the upper bounds of the bins of GenomeStats.WeightHist; the
+// last bin holds all the larger weights.
+var WeightBins = []float64{-4.0, -2.0, -1.0, -0.5, -0.1, 0.1, 0.5, 1.0,
+	2.0, 4.0, math.Inf(1)}
+
+// Inspect computes the structural statistics of the genome, and lists the
+// invariants it violates and its weights that are not finite.
+func (g *Genome) Inspect() *GenomeStats {
+	gr := newGenomeGraph(g)
+	s := &GenomeStats{
+		NumNodes:    len(gr.Nodes),
+		NumEdges:    len(gr.Edges),
+		NodesByType: make(map[string]int),
+		EdgesByType: make(map[string]int),
+		AFuncs:      make(map[string]int),
+		WeightHist:  make([]int, len(WeightBins)),
+		DeadNodes:   make([]int, 0),
+	}
+
+	types := make(map[int]string)
+	for _, n := range gr.Nodes {
+		types[n.ID] = n.Type
+		s.NodesByType[n.Type]++
+		if n.Type == "hidden" {
+			s.AFuncs[n.AFunc]++
+		}
+	}
+
+	// layers
+	layer := gr.layers()
+	for _, l := range layer {
+		if l > s.Depth {
+			s.Depth = l
+		}
+	}
+	s.LayerWidths = make([]int, s.Depth+1)
+	for _, l := range layer {
+		s.LayerWidths[l]++
+	}
+
+	// weights and fan-in/fan-out
+	fanIn, fanOut := make(map[int]int), make(map[int]int)
+	s.WeightMin, s.WeightMax = math.Inf(1), math.Inf(-1)
+	for _, e := range gr.Edges {
+		s.EdgesByType[types[e.From]+"->"+types[e.To]]++
+		fanIn[e.To]++
+		fanOut[e.From]++
+
+		if math.IsNaN(e.Weight) || math.IsInf(e.Weight, 0) {
+			s.NonFinite++
+			continue
+		}
+		s.WeightMin = math.Min(s.WeightMin, e.Weight)
+		s.WeightMax = math.Max(s.WeightMax, e.Weight)
+		s.WeightMean += e.Weight
+		s.WeightHist[sort.SearchFloat64s(WeightBins, e.Weight)]++
+	}
+	if numWeights := len(gr.Edges) - s.NonFinite; numWeights > 0 {
+		s.WeightMean /= float64(numWeights)
+		for _, e := range gr.Edges {
+			if !math.IsNaN(e.Weight) && !math.IsInf(e.Weight, 0) {
+				s.WeightStdDev += (e.Weight - s.WeightMean) *
+					(e.Weight - s.WeightMean)
+			}
+		}
+		s.WeightStdDev = math.Sqrt(s.WeightStdDev / float64(numWeights))
+	} else {
+		s.WeightMin, s.WeightMax = 0.0, 0.0
+	}
+
+	numIn, numOut := 0, 0
+	for _, n := range gr.Nodes {
+		if fanIn[n.ID] > s.MaxFanIn {
+			s.MaxFanIn = fanIn[n.ID]
+		}
+		if fanOut[n.ID] > s.MaxFanOut {
+			s.MaxFanOut = fanOut[n.ID]
+		}
+		if n.Type != "input" {
+			s.MeanFanIn += float64(fanIn[n.ID])
+			numIn++
+		}
+		if n.Type != "output" {
+			s.MeanFanOut += float64(fanOut[n.ID])
+			numOut++
+		}
+	}
+	if numIn > 0 {
+		s.MeanFanIn /= float64(numIn)
+	}
+	if numOut > 0 {
+		s.MeanFanOut /= float64(numOut)
+	}
+
+	// nodes that contribute nothing
+	for id := range gr.dead() {
+		s.DeadNodes = append(s.DeadNodes, id)
+	}
+	sort.Ints(s.DeadNodes)
+	toOutputs := gr.reachable("output", true)
+	for _, n := range gr.Nodes {
+		if n.Type == "input" && !toOutputs[n.ID] {
+			s.UnusedInputs = append(s.UnusedInputs, n.ID)
+		}
+	}
+
+	// problems that make the genome render wrongly, or not at all
+	if err, ok := g.Validate().(*ValidationError); ok {
+		s.Problems = append(s.Problems, err.Problems...)
+	}
+	if s.NonFinite > 0 {
+		s.Problems = append(s.Problems, fmt.Sprintf(
+			"%d of %d weights are NaN or infinite", s.NonFinite, s.NumEdges))
+	}
+
+	return s
+}
+
+// sortedKeys returns the keys of the argument map in order.
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ToString summarizes the statistics in a report.
+func (s *GenomeStats) ToString() string {
+	var b strings.Builder
+
+	if len(s.Problems) > 0 {
+		fmt.Fprintf(&b, "Problems: %d\n", len(s.Problems))
+		for _, p := range s.Problems {
+			fmt.Fprintf(&b, "  %s\n", p)
+		}
+	}
+	fmt.Fprintf(&b, "Nodes: %d\n", s.NumNodes)
+	for _, key := range sortedKeys(s.NodesByType) {
+		fmt.Fprintf(&b, "  %-16s %6d\n", key, s.NodesByType[key])
+	}
+	fmt.Fprintf(&b, "Edges: %d\n", s.NumEdges)
+	for _, key := range sortedKeys(s.EdgesByType) {
+		fmt.Fprintf(&b, "  %-16s %6d\n", key, s.EdgesByType[key])
+	}
+	b.WriteString("Activation functions (hidden nodes):\n")
+	for _, key := range sortedKeys(s.AFuncs) {
+		fmt.Fprintf(&b, "  %-16s %6d\n", key, s.AFuncs[key])
+	}
+
+	fmt.Fprintf(&b, "Depth: %d\n", s.Depth)
+	b.WriteString("Width per layer:")
+	for _, w := range s.LayerWidths {
+		fmt.Fprintf(&b, " %d", w)
+	}
+	b.WriteString("\n")
+
+	fmt.Fprintf(&b, "Weights: min %f, max %f, mean %f, stddev %f\n",
+		s.WeightMin, s.WeightMax, s.WeightMean, s.WeightStdDev)
+	lower := math.Inf(-1)
+	for i, upper := range WeightBins {
+		fmt.Fprintf(&b, "  (%5.1f, %5.1f] %6d\n", lower, upper, s.WeightHist[i])
+		lower = upper
+	}
+	if s.NonFinite > 0 {
+		fmt.Fprintf(&b, "  NaN or Inf     %6d\n", s.NonFinite)
+	}
+
+	fmt.Fprintf(&b, "Fan-in: max %d, mean %.2f\n", s.MaxFanIn, s.MeanFanIn)
+	fmt.Fprintf(&b, "Fan-out: max %d, mean %.2f\n", s.MaxFanOut, s.MeanFanOut)
+
+	fmt.Fprintf(&b, "Dead nodes: %d of %d hidden (%.1f%%) %v\n",
+		len(s.DeadNodes), s.NodesByType["hidden"],
+		100.0*float64(len(s.DeadNodes))/
+			math.Max(1.0, float64(s.NodesByType["hidden"])), s.DeadNodes)
+	fmt.Fprintf(&b, "Unused inputs: %v", s.UnusedInputs)
+
+	return b.String()
+}
+
+// inspect prints the structural statistics of exported genomes.
+func inspect(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: imagen inspect [genome].txt ...")
+	}
+
+	for i, filename := range args {
+		g, err := LoadGenome(filename, i)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("=== %s ===\n", filename)
+		fmt.Println(g.Inspect().ToString())
+	}
+	return nil
+}
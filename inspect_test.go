@@ -0,0 +1,107 @@
+/*
+
+
+inspect_test.go tests for genome statistics.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	g := testGraphGenome()
+	s := g.Inspect()
+
+	if s.NumNodes != 5 || s.NumEdges != 4 || s.NodesByType["hidden"] != 3 ||
+		s.EdgesByType["input->hidden"] != 2 || s.AFuncs["tanh"] != 1 {
+		t.Errorf("counts are %+v", s)
+	}
+	if s.Depth != 3 || len(s.LayerWidths) != 4 || s.LayerWidths[1] != 2 {
+		t.Errorf("depth is %d, layer widths %v", s.Depth, s.LayerWidths)
+	}
+	if s.WeightMin != -1.0 || s.WeightMax != 2.0 || s.WeightMean != 0.625 {
+		t.Errorf("weights are in [%f, %f], mean %f", s.WeightMin,
+			s.WeightMax, s.WeightMean)
+	}
+	if s.MaxFanOut != 2 || s.MaxFanIn != 1 {
+		t.Errorf("fan-in is %d, fan-out %d", s.MaxFanIn, s.MaxFanOut)
+	}
+	if len(s.DeadNodes) != 1 || s.DeadNodes[0] != 4 {
+		t.Errorf("dead nodes are %v, expected [4]", s.DeadNodes)
+	}
+	if len(s.Problems) != 0 {
+		t.Errorf("valid genome has problems %v", s.Problems)
+	}
+
+	// non-finite weights are reported, and left out of the statistics
+	g.EdgeGenes[0].Weight = math.NaN()
+	g.EdgeGenes[1].Weight = math.Inf(-1)
+	s = g.Inspect()
+	if s.NonFinite != 2 || s.WeightMin != 0.5 || s.WeightMax != 1.0 {
+		t.Errorf("%d weights are not finite, the others are in [%f, %f]",
+			s.NonFinite, s.WeightMin, s.WeightMax)
+	}
+	if len(s.Problems) != 1 ||
+		!strings.Contains(s.ToString(), "2 of 4 weights are NaN or infinite") {
+		t.Errorf("report has no problems:\n%s", s.ToString())
+	}
+}
+
+func TestInspectInvalid(t *testing.T) {
+	// the trained weights of this genome diverged, and it has duplicate edges
+	filenames, err := filepath.Glob(filepath.Join("tests", "mnist-5",
+		"genome_30_*.txt"))
+	if err != nil || len(filenames) != 1 {
+		t.Fatalf("genome not found: %v", err)
+	}
+	g, err := LoadGenome(filenames[0], 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := g.Inspect()
+	if s.NonFinite != s.NumEdges {
+		t.Errorf("%d of %d weights are not finite, expected all",
+			s.NonFinite, s.NumEdges)
+	}
+	report := s.ToString()
+	for _, problem := range []string{"duplicate edge",
+		"weights are NaN or infinite"} {
+		if !strings.Contains(report, problem) {
+			t.Errorf("report does not have problem %q", problem)
+		}
+	}
+}
@@ -37,7 +37,9 @@ package main
 
 import (
 	"fmt"
+	"math"
 	"math/rand"
+	"time"
 )
 
 // MGA contains an environment of the microbial Genetic Algorithm (mGA).
@@ -47,12 +49,18 @@ type MGA struct {
 	Population []*Genome      // population of genomes
 	Comparison ComparisonFunc // comparison function
 	Evaluation EvaluationFunc // evaluation function
+	Store      Store          // store of genomes and their lineage, if any
+	RunID      string         // ID of the run in the store
 }
 
 // NewMGA creates a new environment for microbial Genetic Algorithm (mGA). It
 // returns an error if an invalid configuration file is provided.
 func NewMGA(config *Configuration, comparison ComparisonFunc,
 	evaluation EvaluationFunc) (*MGA, error) {
+	if err := config.validateOutputs(); err != nil {
+		return nil, err
+	}
+
 	population := make([]*Genome, config.PopulationSize)
 	for i := range population {
 		population[i] = NewGenome(i, config.NumInputs,
@@ -68,43 +76,132 @@ func NewMGA(config *Configuration, comparison ComparisonFunc,
 	}, nil
 }
 
+// UseStore opens the store at the argument path, and records the genomes of
+// this run and their lineage in it, starting from the initial population.
+func (m *MGA) UseStore(path string) error {
+	store, err := OpenStore(path)
+	if err != nil {
+		return err
+	}
+	m.Store = store
+	m.RunID = fmt.Sprintf("run_%d", time.Now().UnixNano())
+
+	// record the initial population, from which every lineage starts
+	for _, g := range m.Population {
+		r := NewGenomeRecord(g, nil, []string{"init"}, m.RunID)
+		if err := store.Put(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// evaluate evaluates the argument genome, and records the genome with its
+// trained weights in the store, if any.
+func (m *MGA) evaluate(g *Genome) float64 {
+	if m.Store == nil {
+		return m.Evaluation(g)
+	}
+
+	parent := g.Hash()
+	g.Fitness = m.Evaluation(g)
+
+	r := NewGenomeRecord(g, []string{parent}, []string{"train"}, m.RunID)
+	if r.ID == parent {
+		// training left the weights unchanged, so the genome is not its own
+		// parent, and only the fitness score of its record is updated
+		r.Parents, r.Mutations = nil, nil
+	}
+	r.Evaluated = true
+	if err := m.Store.Put(r); err != nil {
+		fmt.Println("Genome store failed:")
+		fmt.Println(err)
+	}
+	return g.Fitness
+}
+
+// breed replaces the loser with the child of crossover between the loser and
+// the winner at the crossover rate, and mutates the argument mutant. Each
+// changed genome is recorded in the store, if any.
+func (m *MGA) breed(loser, winner, mutant *Genome) {
+	if rand.Float64() < m.Config.CrossoverRate {
+		parents := m.parentIDs(loser, winner)
+		loser.Crossover(winner)
+		debugValidate(m.Config, loser, "crossover")
+		m.record(loser, parents, []string{"crossover"})
+	}
+
+	parents := m.parentIDs(mutant)
+	mutations := make([]string, 0)
+	nid, from, to := mutant.Mutate(m.Config.MutAddNodeRate,
+		m.Config.MutAddEdgeRate)
+	debugValidate(m.Config, mutant, "mutation")
+	if nid != -1 {
+		mutations = append(mutations, fmt.Sprintf("add-node %d", nid))
+	}
+	if from != -1 && to != -1 {
+		mutations = append(mutations, fmt.Sprintf("add-edge %d %d", from, to))
+	}
+	m.record(mutant, parents, mutations)
+}
+
+// parentIDs returns the IDs of the argument genomes, before they are changed,
+// if there is a store.
+func (m *MGA) parentIDs(parents ...*Genome) []string {
+	if m.Store == nil {
+		return nil
+	}
+	ids := make([]string, len(parents))
+	for i, g := range parents {
+		ids[i] = g.Hash()
+	}
+	return ids
+}
+
+// record records the argument genome with its parents and the operations
+// applied to them in the store, if any.
+func (m *MGA) record(g *Genome, parents, mutations []string) {
+	if m.Store == nil {
+		return
+	}
+	r := NewGenomeRecord(g, parents, mutations, m.RunID)
+	if err := m.Store.Put(r); err != nil {
+		fmt.Println("Genome store failed:")
+		fmt.Println(err)
+	}
+}
+
 // Run performs microbial Genetic Algorithm (mGA).
 func (m *MGA) Run(verbose, exportLog bool) float64 {
-	bestScore := 0.0
-	if m.Comparison(bestScore, 9999.0) {
-		bestScore = 9999.0
+	bestScore := math.Inf(-1)
+	if m.Comparison(bestScore, math.Inf(1)) {
+		bestScore = math.Inf(1)
 	}
 
 	for i := 0; i < m.Config.NumTournaments; i++ {
 		ind1 := randGenome(m.Population)
 		ind2 := randGenome(m.Population)
 
-		ind1.Fitness = m.Evaluation(ind1)
-		ind2.Fitness = m.Evaluation(ind2)
+		ind1.Fitness = m.evaluate(ind1)
+		ind2.Fitness = m.evaluate(ind2)
 
 		if m.Comparison(ind1.Fitness, ind2.Fitness) {
 			// if score 1 (ind1) is better than score 2 (ind2),
 			// perform crossover between the two, and update ind2
 			// with the resulting child, and mutate it.
-			if rand.Float64() < m.Config.CrossoverRate {
-				ind2.Crossover(ind1)
-			}
-			ind2.Mutate(m.Config.MutAddNodeRate, m.Config.MutAddEdgeRate)
+			m.breed(ind2, ind1, ind2)
 
 			if m.Comparison(ind1.Fitness, bestScore) {
-				m.Log.Best = ind1
+				m.Log.Best = ind1.Clone()
 				bestScore = ind1.Fitness
 			}
 		} else {
 			// otherwise, update ind1 (loser) with the resulting
 			// child, and mutate it.
-			if rand.Float64() < m.Config.CrossoverRate {
-				ind1.Crossover(ind2)
-			}
-			ind2.Mutate(m.Config.MutAddNodeRate, m.Config.MutAddEdgeRate)
+			m.breed(ind1, ind2, ind2)
 
 			if m.Comparison(ind2.Fitness, bestScore) {
-				m.Log.Best = ind2
+				m.Log.Best = ind2.Clone()
 				bestScore = ind2.Fitness
 			}
 		}
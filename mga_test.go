@@ -0,0 +1,129 @@
+/*
+
+
+mga_test.go tests for the microbial genetic algorithm.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"math/rand"
+	"path/filepath"
+	"testing"
+)
+
+func TestMGATournament(t *testing.T) {
+	rand.Seed(0)
+
+	config := DefaultConfiguration()
+	config.PopulationSize, config.NumTournaments = 2, 1
+	config.MutAddNodeRate, config.MutAddEdgeRate = 1.0, 0.0
+	config.CrossoverRate = 0.0
+
+	// the fitness of a genome is its ID, so genome 1 wins every tournament
+	// against genome 0
+	evaluated := make([]*Genome, 0)
+	m, err := NewMGA(config, DirectComparison(), func(g *Genome) float64 {
+		evaluated = append(evaluated, g)
+		return float64(g.ID)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// as in the original algorithm, the second genome of a tournament is
+	// mutated, whether it wins or loses
+	numDistinct := 0
+	for i := 0; i < 20; i++ {
+		hashes := map[*Genome]string{m.Population[0]: m.Population[0].Hash(),
+			m.Population[1]: m.Population[1].Hash()}
+		evaluated = evaluated[:0]
+		m.Run(false, false)
+		ind1, ind2 := evaluated[0], evaluated[1]
+		if ind1 == ind2 {
+			continue
+		}
+		numDistinct++
+
+		if ind1.Hash() != hashes[ind1] {
+			t.Errorf("first genome %d is changed", ind1.ID)
+		}
+		if ind2.Hash() == hashes[ind2] {
+			t.Errorf("second genome %d is not mutated", ind2.ID)
+		}
+	}
+	if numDistinct == 0 {
+		t.Error("no tournament between distinct genomes")
+	}
+}
+
+func TestMGAStoreUnchanged(t *testing.T) {
+	rand.Seed(0)
+
+	config := DefaultConfiguration()
+	config.PopulationSize = 2
+
+	// evaluation leaves the weights unchanged, so each evaluated genome keeps
+	// its record, with an updated fitness score
+	m, err := NewMGA(config, DirectComparison(), func(g *Genome) float64 {
+		return 0.5
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.UseStore(filepath.Join(t.TempDir(), "records.db")); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Store.Close()
+
+	g := m.Population[0]
+	m.evaluate(g)
+	r, err := m.Store.Get(g.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, parent := range r.Parents {
+		if parent == r.ID {
+			t.Errorf("genome %s is its own parent", r.ID)
+		}
+	}
+	if !r.Evaluated || r.Fitness != 0.5 {
+		t.Errorf("record of the evaluated genome is %+v", r)
+	}
+	ancestors, err := Ancestors(m.Store, r.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ancestors) != 0 {
+		t.Errorf("genome %s has ancestors %v", r.ID, recordIDs(ancestors))
+	}
+}
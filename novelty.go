@@ -0,0 +1,232 @@
+/*
+
+
+novelty.go implementation of novelty search for mGA.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"errors"
+	"image"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// NoveltyArchive keeps the behavior descriptors of past rendered outputs,
+// against which the novelty of a new genome is measured.
+type NoveltyArchive struct {
+	K           int         // number of nearest neighbors
+	Capacity    int         // maximum number of descriptors
+	Descriptors [][]float64 // archived descriptors
+}
+
+// NewNoveltyArchive creates a new empty archive, given the number of nearest
+// neighbors and the maximum number of descriptors it keeps.
+func NewNoveltyArchive(k, capacity int) *NoveltyArchive {
+	return &NoveltyArchive{
+		K:           k,
+		Capacity:    capacity,
+		Descriptors: make([][]float64, 0),
+	}
+}
+
+// Novelty returns the mean Euclidean distance from the argument descriptor
+// to its k nearest neighbors in the archive. It returns 0.0 if the archive
+// is empty, or if k is not positive.
+func (a *NoveltyArchive) Novelty(desc []float64) float64 {
+	if len(a.Descriptors) == 0 || a.K <= 0 {
+		return 0.0
+	}
+
+	dists := make([]float64, len(a.Descriptors))
+	for i, d := range a.Descriptors {
+		sum := 0.0
+		for j := range d {
+			sum += (d[j] - desc[j]) * (d[j] - desc[j])
+		}
+		dists[i] = math.Sqrt(sum)
+	}
+	sort.Float64s(dists)
+
+	k := a.K
+	if k > len(dists) {
+		k = len(dists)
+	}
+	novelty := 0.0
+	for _, d := range dists[:k] {
+		novelty += d
+	}
+	return novelty / float64(k)
+}
+
+// Add archives the argument descriptor. Once the archive is full, a randomly
+// selected descriptor is replaced. Nothing is archived if the capacity is not
+// positive.
+func (a *NoveltyArchive) Add(desc []float64) {
+	if a.Capacity <= 0 {
+		return
+	}
+	if len(a.Descriptors) < a.Capacity {
+		a.Descriptors = append(a.Descriptors, desc)
+		return
+	}
+	a.Descriptors[rand.Intn(len(a.Descriptors))] = desc
+}
+
+// downsample averages the argument image over a size x size grid of cells,
+// and returns the RGB values of the cells in [0, 1] as a flat slice.
+func downsample(img *image.RGBA, size int) []float64 {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	desc := make([]float64, 0, 3*size*size)
+	for cy := 0; cy < size; cy++ {
+		for cx := 0; cx < size; cx++ {
+			x0, x1 := cx*width/size, (cx+1)*width/size
+			y0, y1 := cy*height/size, (cy+1)*height/size
+			r, g, b, n := 0.0, 0.0, 0.0, 0.0
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					c := img.RGBAAt(bounds.Min.X+x, bounds.Min.Y+y)
+					r += float64(c.R) / 255.0
+					g += float64(c.G) / 255.0
+					b += float64(c.B) / 255.0
+					n++
+				}
+			}
+			if n > 0 {
+				r, g, b = r/n, g/n, b/n
+			}
+			desc = append(desc, r, g, b)
+		}
+	}
+	return desc
+}
+
+// genNovelty returns an evaluation function that scores a genome by the
+// novelty of its downsampled render relative to the archive, then archives
+// it. Higher scores are better. If recon is not nil, the score is blended
+// with the reconstruction error it returns, weighted by blend in [0, 1].
+// Both terms of the blend are on the scale of a color component: novelty is
+// divided by the square root of the descriptor's length, and the
+// reconstruction error, a sum over numEpochs epochs, by numEpochs.
+func genNovelty(archive *NoveltyArchive, opts *RenderOptions, size int,
+	blend float64, numEpochs int, recon EvaluationFunc) EvaluationFunc {
+	return func(g *Genome) float64 {
+		// train the genome first, so that novelty is measured on what the
+		// genome actually renders after training.
+		reconErr := 0.0
+		if recon != nil {
+			reconErr = recon(g)
+		}
+
+		desc := downsample(render(g, opts), size)
+		score := archive.Novelty(desc)
+		archive.Add(desc)
+
+		if recon == nil {
+			return score
+		}
+		if numEpochs > 1 {
+			reconErr /= float64(numEpochs)
+		}
+		if len(desc) > 0 {
+			score /= math.Sqrt(float64(len(desc)))
+		}
+		return (1.0-blend)*score - blend*reconErr
+	}
+}
+
+// novelty runs novelty search, given a configuration file and an optional
+// target image whose reconstruction error is blended into the score.
+func novelty(args []string) error {
+	if len(args) != 1 && len(args) != 2 {
+		return errors.New("usage: imagen novelty [config].json [[filename].png]")
+	}
+
+	config, err := NewConfiguration(args[0])
+	if err != nil {
+		return err
+	}
+
+	rand.Seed(config.Seed)
+
+	width, height := config.Width, config.Height
+	var recon EvaluationFunc
+	if len(args) == 2 {
+		img, err := loadImage(args[1])
+		if err != nil {
+			return err
+		}
+		width, height = img.Bounds().Dx(), img.Bounds().Dy()
+		config.Width, config.Height = width, height
+		config.extractPalette(img)
+		recon = genImage(img, config.BatchSize, config.NumEpochs,
+			config.LearningRate, config.RenderOptions(width, height))
+	}
+	if width <= 0 || height <= 0 {
+		return errors.New("invalid render size for novelty search")
+	}
+	if config.NoveltyK <= 0 || config.NoveltyArchiveSize <= 0 ||
+		config.NoveltyResolution <= 0 {
+		return errors.New("novelty search needs positive NoveltyK, " +
+			"NoveltyArchiveSize and NoveltyResolution")
+	}
+	if config.ColorSpace == "palette" && len(config.Palette) == 0 {
+		return errors.New("palette outputs need a palette or a target image")
+	}
+
+	archive := NewNoveltyArchive(config.NoveltyK, config.NoveltyArchiveSize)
+	env, err := NewMGA(config, DirectComparison(),
+		genNovelty(archive, config.RenderOptions(width, height),
+			config.NoveltyResolution, config.NoveltyBlend, config.NumEpochs,
+			recon))
+	if err != nil {
+		return err
+	}
+	if config.StorePath != "" {
+		if err := env.UseStore(config.StorePath); err != nil {
+			return err
+		}
+		defer env.Store.Close()
+	}
+	env.Run(true, true)
+
+	// export all the images and genomes in the population
+	opts, err := config.FinalRenderOptions(width, height)
+	if err != nil {
+		return err
+	}
+	return exportPopulation(env.Population, config, opts)
+}
@@ -0,0 +1,148 @@
+/*
+
+
+novelty_test.go tests for novelty search.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestNoveltyArchive(t *testing.T) {
+	rand.Seed(0)
+
+	a := NewNoveltyArchive(2, 3)
+	if n := a.Novelty([]float64{0.0, 0.0}); n != 0.0 {
+		t.Errorf("novelty in an empty archive is %f, expected 0", n)
+	}
+	for _, d := range [][]float64{{0.0, 1.0}, {3.0, 0.0}, {0.0, 5.0}} {
+		a.Add(d)
+	}
+
+	// mean distance to the 2 nearest neighbors, (1 + 3) / 2
+	if n := a.Novelty([]float64{0.0, 0.0}); math.Abs(n-2.0) > 1e-12 {
+		t.Errorf("novelty is %f, expected 2", n)
+	}
+	// k is limited by the number of descriptors
+	a.K = 10
+	if n := a.Novelty([]float64{0.0, 0.0}); math.Abs(n-3.0) > 1e-12 {
+		t.Errorf("novelty is %f, expected 3", n)
+	}
+
+	// a full archive replaces descriptors
+	a.Add([]float64{7.0, 7.0})
+	if len(a.Descriptors) != 3 {
+		t.Errorf("archive has %d descriptors, expected 3", len(a.Descriptors))
+	}
+
+	// an archive without capacity or neighbors does not fail
+	a = NewNoveltyArchive(0, 0)
+	a.Add([]float64{1.0})
+	if n := a.Novelty([]float64{0.0}); len(a.Descriptors) != 0 || n != 0.0 {
+		t.Errorf("archive without capacity has %v, novelty %f",
+			a.Descriptors, n)
+	}
+	a = NewNoveltyArchive(0, 2)
+	a.Add([]float64{1.0})
+	if n := a.Novelty([]float64{0.0}); n != 0.0 || math.IsNaN(n) {
+		t.Errorf("novelty without neighbors is %f, expected 0", n)
+	}
+}
+
+func TestDownsample(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			img.SetRGBA(x, y, color.RGBA{uint8(255 * (x / 2)), 0, 51, 255})
+		}
+	}
+	desc := downsample(img, 2)
+	expected := []float64{0.0, 0.0, 0.2, 1.0, 0.0, 0.2, 0.0, 0.0, 0.2,
+		1.0, 0.0, 0.2}
+	if len(desc) != len(expected) {
+		t.Fatalf("descriptor is %v, expected %v", desc, expected)
+	}
+	for i, v := range expected {
+		if math.Abs(desc[i]-v) > 1e-12 {
+			t.Fatalf("descriptor is %v, expected %v", desc, expected)
+		}
+	}
+}
+
+func TestGenNoveltyBlend(t *testing.T) {
+	rand.Seed(0)
+
+	config := DefaultConfiguration()
+	opts := config.RenderOptions(8, 8)
+	g := NewGenome(0, config.NumInputs, config.NumInitHidden,
+		config.NumOutputs)
+
+	// the first genome is as novel as a shift of 0.5 in every component, and
+	// reconstructs with a mean error of 0.8. The second renders the same, so
+	// it is not novel, and reconstructs with a mean error of 0.1. The scores
+	// are the same at every resolution.
+	errs := map[int]float64{0: 8.0, 1: 1.0}
+	recon := func(g *Genome) float64 {
+		return errs[g.ID]
+	}
+	for _, size := range []int{2, 8} {
+		archive := NewNoveltyArchive(1, 10)
+		desc := downsample(render(g, opts), size)
+		shifted := make([]float64, len(desc))
+		for i, v := range desc {
+			shifted[i] = v + 0.5
+		}
+		archive.Add(shifted)
+
+		eval := genNovelty(archive, opts, size, 0.5, 10, recon)
+		clone := g.Clone()
+		clone.ID = 1
+		scores := []float64{eval(g), eval(clone)}
+		expected := []float64{0.5*0.5 - 0.5*0.8, 0.5*0.0 - 0.5*0.1}
+		for i := range scores {
+			if math.Abs(scores[i]-expected[i]) > 1e-9 {
+				t.Errorf("resolution %d: genome %d scores %f, expected %f",
+					size, i, scores[i], expected[i])
+			}
+		}
+		if scores[0] >= scores[1] {
+			t.Errorf("resolution %d: novel genome scores %f, better than %f",
+				size, scores[0], scores[1])
+		}
+	}
+}
@@ -0,0 +1,343 @@
+/*
+
+
+nsga.go implementation of multi-objective optimization via NSGA-II.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"time"
+)
+
+// ObjectiveFunc defines a type of function that evaluates a genome and
+// returns its scores on multiple objectives, all of which are minimized.
+type ObjectiveFunc func(g *Genome) []float64
+
+// Individual is a genome in NSGA-II along with its objective scores, its
+// Pareto rank, and its crowding distance.
+type Individual struct {
+	Genome     *Genome   // genome
+	Objectives []float64 // objective scores (lower is better)
+	Rank       int       // Pareto front index (0 is non-dominated)
+	Crowding   float64   // crowding distance in its front
+}
+
+// dominates returns true if the first individual is no worse than the second
+// on every objective, and strictly better on at least one.
+func (i *Individual) dominates(j *Individual) bool {
+	better := false
+	for k := range i.Objectives {
+		if i.Objectives[k] > j.Objectives[k] {
+			return false
+		}
+		if i.Objectives[k] < j.Objectives[k] {
+			better = true
+		}
+	}
+	return better
+}
+
+// NSGA contains an environment of the Non-dominated Sorting Genetic Algorithm
+// (NSGA-II).
+type NSGA struct {
+	Config     *Configuration // configuration
+	Population []*Individual  // population of individuals
+	Objectives ObjectiveFunc  // objective function
+	nextID     int            // ID of the next offspring genome
+}
+
+// NewNSGA creates a new environment for NSGA-II, and evaluates its initial
+// population.
+func NewNSGA(config *Configuration, objectives ObjectiveFunc) (*NSGA, error) {
+	if config.PopulationSize < 2 {
+		return nil, errors.New("population size must be at least 2")
+	}
+	if err := config.validateOutputs(); err != nil {
+		return nil, err
+	}
+
+	population := make([]*Individual, config.PopulationSize)
+	for i := range population {
+		g := NewGenome(i, config.NumInputs, config.NumInitHidden,
+			config.NumOutputs)
+		population[i] = &Individual{Genome: g, Objectives: objectives(g)}
+	}
+	sortPopulation(population)
+
+	return &NSGA{
+		Config:     config,
+		Population: population,
+		Objectives: objectives,
+		nextID:     config.PopulationSize,
+	}, nil
+}
+
+// nonDominatedSort assigns each individual its Pareto rank, and returns the
+// individuals grouped by front.
+func nonDominatedSort(population []*Individual) [][]*Individual {
+	dominated := make([][]int, len(population)) // indices each one dominates
+	counts := make([]int, len(population))      // number dominating each one
+
+	fronts := [][]*Individual{{}}
+	current := make([]int, 0)
+	for i, p := range population {
+		for j, q := range population {
+			if p.dominates(q) {
+				dominated[i] = append(dominated[i], j)
+			} else if q.dominates(p) {
+				counts[i]++
+			}
+		}
+		if counts[i] == 0 {
+			p.Rank = 0
+			current = append(current, i)
+			fronts[0] = append(fronts[0], p)
+		}
+	}
+
+	for rank := 1; len(current) > 0; rank++ {
+		next := make([]int, 0)
+		front := make([]*Individual, 0)
+		for _, i := range current {
+			for _, j := range dominated[i] {
+				counts[j]--
+				if counts[j] == 0 {
+					population[j].Rank = rank
+					next = append(next, j)
+					front = append(front, population[j])
+				}
+			}
+		}
+		if len(front) > 0 {
+			fronts = append(fronts, front)
+		}
+		current = next
+	}
+
+	return fronts
+}
+
+// assignCrowding computes the crowding distance of each individual in the
+// argument front.
+func assignCrowding(front []*Individual) {
+	for _, ind := range front {
+		ind.Crowding = 0.0
+	}
+	if len(front) == 0 {
+		return
+	}
+
+	for k := range front[0].Objectives {
+		sort.Slice(front, func(i, j int) bool {
+			return front[i].Objectives[k] < front[j].Objectives[k]
+		})
+		front[0].Crowding = math.Inf(1)
+		front[len(front)-1].Crowding = math.Inf(1)
+
+		span := front[len(front)-1].Objectives[k] - front[0].Objectives[k]
+		if span == 0.0 {
+			continue
+		}
+		for i := 1; i < len(front)-1; i++ {
+			front[i].Crowding += (front[i+1].Objectives[k] -
+				front[i-1].Objectives[k]) / span
+		}
+	}
+}
+
+// sortPopulation ranks the argument population, and sorts it by rank and by
+// crowding distance, best first.
+func sortPopulation(population []*Individual) {
+	for _, front := range nonDominatedSort(population) {
+		assignCrowding(front)
+	}
+	sort.SliceStable(population, func(i, j int) bool {
+		return crowdedLess(population[i], population[j])
+	})
+}
+
+// crowdedLess returns true if the first individual is preferred over the
+// second by the crowded-comparison operator.
+func crowdedLess(i, j *Individual) bool {
+	if i.Rank != j.Rank {
+		return i.Rank < j.Rank
+	}
+	return i.Crowding > j.Crowding
+}
+
+// selectParent selects a parent via binary tournament.
+func (n *NSGA) selectParent() *Individual {
+	i := n.Population[rand.Intn(len(n.Population))]
+	j := n.Population[rand.Intn(len(n.Population))]
+	if crowdedLess(j, i) {
+		return j
+	}
+	return i
+}
+
+// Run performs NSGA-II, and returns the final Pareto front.
+func (n *NSGA) Run(verbose bool) []*Individual {
+	for gen := 0; gen < n.Config.NumGenerations; gen++ {
+		offspring := make([]*Individual, 0, len(n.Population))
+		for len(offspring) < len(n.Population) {
+			p1, p2 := n.selectParent(), n.selectParent()
+
+			child := p1.Genome.Clone()
+			child.ID = n.nextID
+			n.nextID++
+			if rand.Float64() < n.Config.CrossoverRate {
+				child.Crossover(p2.Genome)
+				debugValidate(n.Config, child, "crossover")
+			}
+			child.Mutate(n.Config.MutAddNodeRate, n.Config.MutAddEdgeRate)
+			debugValidate(n.Config, child, "mutation")
+
+			offspring = append(offspring,
+				&Individual{Genome: child, Objectives: n.Objectives(child)})
+		}
+
+		// select the next population from parents and offspring
+		combined := append(offspring, n.Population...)
+		sortPopulation(combined)
+		n.Population = combined[:len(n.Population)]
+
+		if verbose {
+			fmt.Printf("Generation [%4d] | front size: %3d\n",
+				gen, len(n.Front()))
+		}
+	}
+
+	return n.Front()
+}
+
+// Front returns the individuals in the current non-dominated front.
+func (n *NSGA) Front() []*Individual {
+	front := make([]*Individual, 0)
+	for _, ind := range n.Population {
+		if ind.Rank == 0 {
+			front = append(front, ind)
+		}
+	}
+	return front
+}
+
+// genObjectives returns an objective function that scores a genome by its
+// reconstruction error of the argument image, its number of nodes, its
+// number of edges, and the time it takes to evaluate, in seconds.
+func genObjectives(eval EvaluationFunc) ObjectiveFunc {
+	return func(g *Genome) []float64 {
+		start := time.Now()
+		g.Fitness = eval(g)
+		elapsed := time.Since(start).Seconds()
+
+		return []float64{
+			g.Fitness,
+			float64(len(g.NodeGenes)),
+			float64(len(g.EdgeGenes)),
+			elapsed,
+		}
+	}
+}
+
+// ExportFront exports the argument Pareto front's genomes with the metadata of
+// the argument training configuration and their renders, along with a CSV
+// summary of their objective scores.
+func ExportFront(front []*Individual, config *Configuration,
+	opts *RenderOptions) error {
+	f, err := os.Create(fmt.Sprintf("pareto_%d.csv", time.Now().UnixNano()))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString("id,error,nodes,edges,seconds\n"); err != nil {
+		return err
+	}
+	for _, ind := range front {
+		dat := fmt.Sprintf("%d,%f,%d,%d,%f", ind.Genome.ID,
+			ind.Objectives[0], int(ind.Objectives[1]),
+			int(ind.Objectives[2]), ind.Objectives[3])
+		if _, err := f.WriteString(dat + "\n"); err != nil {
+			return err
+		}
+
+		draw(ind.Genome, opts)
+		if _, err := ExportGenome(ind.Genome, config); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pareto runs NSGA-II on reconstruction error versus genome complexity, and
+// exports the final Pareto front.
+func pareto(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: imagen pareto [filename].png [config].json")
+	}
+
+	img, err := loadImage(args[0])
+	if err != nil {
+		return err
+	}
+	config, err := NewConfiguration(args[1])
+	if err != nil {
+		return err
+	}
+
+	rand.Seed(config.Seed)
+	config.extractPalette(img)
+	config.Width, config.Height = img.Bounds().Dx(), img.Bounds().Dy()
+
+	env, err := NewNSGA(config, genObjectives(genImage(img,
+		config.BatchSize, config.NumEpochs, config.LearningRate,
+		config.RenderOptions(img.Bounds().Dx(), img.Bounds().Dy()))))
+	if err != nil {
+		return err
+	}
+	front := env.Run(true)
+
+	opts, err := config.FinalRenderOptions(img.Bounds().Dx(),
+		img.Bounds().Dy())
+	if err != nil {
+		return err
+	}
+	return ExportFront(front, config, opts)
+}
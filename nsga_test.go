@@ -0,0 +1,133 @@
+/*
+
+
+nsga_test.go tests for NSGA-II.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// individuals returns individuals with the argument objective scores.
+func individuals(objectives ...[]float64) []*Individual {
+	population := make([]*Individual, len(objectives))
+	for i, o := range objectives {
+		population[i] = &Individual{Genome: &Genome{ID: i}, Objectives: o}
+	}
+	return population
+}
+
+func TestNonDominatedSort(t *testing.T) {
+	population := individuals(
+		[]float64{1.0, 4.0}, // front 0
+		[]float64{2.0, 2.0}, // front 0
+		[]float64{4.0, 1.0}, // front 0
+		[]float64{2.0, 4.0}, // front 1, dominated by 0
+		[]float64{3.0, 3.0}, // front 1, dominated by 1
+		[]float64{4.0, 4.0}, // front 2, dominated by 3 and 4
+		[]float64{2.0, 2.0}, // front 0, equal to 1
+	)
+	fronts := nonDominatedSort(population)
+	expected := [][]int{{0, 1, 2, 6}, {3, 4}, {5}}
+	if len(fronts) != len(expected) {
+		t.Fatalf("%d fronts, expected %d", len(fronts), len(expected))
+	}
+	for rank, front := range fronts {
+		ids := make(map[int]bool)
+		for _, ind := range front {
+			ids[ind.Genome.ID] = true
+			if ind.Rank != rank {
+				t.Errorf("individual %d has rank %d in front %d",
+					ind.Genome.ID, ind.Rank, rank)
+			}
+		}
+		for _, id := range expected[rank] {
+			if !ids[id] || len(ids) != len(expected[rank]) {
+				t.Errorf("front %d is %v, expected individuals %v", rank,
+					ids, expected[rank])
+				break
+			}
+		}
+	}
+
+	if !population[1].dominates(population[4]) ||
+		population[1].dominates(population[6]) ||
+		population[0].dominates(population[2]) {
+		t.Error("dominance is wrong")
+	}
+}
+
+func TestCrowding(t *testing.T) {
+	front := individuals(
+		[]float64{0.0, 4.0},
+		[]float64{1.0, 3.0},
+		[]float64{3.0, 1.0},
+		[]float64{4.0, 0.0},
+	)
+	assignCrowding(front)
+
+	// boundary individuals are infinitely far, and inner ones sum the
+	// normalized distances between their neighbors on each objective
+	expected := map[int]float64{0: math.Inf(1), 1: 1.5, 2: 1.5,
+		3: math.Inf(1)}
+	for _, ind := range front {
+		if ind.Crowding != expected[ind.Genome.ID] {
+			t.Errorf("individual %d has crowding %f, expected %f",
+				ind.Genome.ID, ind.Crowding, expected[ind.Genome.ID])
+		}
+	}
+
+	// the population is sorted by rank, then by crowding
+	population := individuals(
+		[]float64{5.0, 5.0},
+		[]float64{1.0, 3.0},
+		[]float64{0.0, 4.0},
+		[]float64{2.0, 2.5},
+		[]float64{4.0, 0.0},
+	)
+	sortPopulation(population)
+	if population[4].Genome.ID != 0 {
+		t.Errorf("dominated individual %d is not last", population[4].Genome.ID)
+	}
+	for i := 0; i < 2; i++ {
+		if id := population[i].Genome.ID; id != 2 && id != 4 {
+			t.Errorf("individual %d is not a boundary of the front", id)
+		}
+	}
+	if !crowdedLess(population[0], population[3]) ||
+		crowdedLess(population[4], population[3]) {
+		t.Error("crowded comparison is wrong")
+	}
+}
@@ -0,0 +1,344 @@
+/*
+
+
+onnx.go implementation of ONNX model export of DPPNs.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+)
+
+// ONNX format versions of exported models.
+const (
+	onnxIRVersion = 7  // IR version, supported by opset 13
+	onnxOpset     = 13 // version of the default operator set
+)
+
+// ONNX tensor element types.
+const (
+	onnxFloat = 1
+	onnxInt64 = 7
+)
+
+// protoMessage is an encoded protocol buffers message, as much of the wire
+// format as ONNX models need.
+type protoMessage []byte
+
+// varint appends an unsigned varint.
+func (m *protoMessage) varint(v uint64) {
+	*m = protoMessage(binary.AppendUvarint([]byte(*m), v))
+}
+
+// Int appends an integer field.
+func (m *protoMessage) Int(field int, v int64) {
+	m.varint(uint64(field)<<3 | 0)
+	m.varint(uint64(v))
+}
+
+// Float appends a 32-bit float field.
+func (m *protoMessage) Float(field int, v float32) {
+	m.varint(uint64(field)<<3 | 5)
+	*m = protoMessage(binary.LittleEndian.AppendUint32([]byte(*m),
+		math.Float32bits(v)))
+}
+
+// Bytes appends a length-delimited field.
+func (m *protoMessage) Bytes(field int, b []byte) {
+	m.varint(uint64(field)<<3 | 2)
+	m.varint(uint64(len(b)))
+	*m = append(*m, b...)
+}
+
+// String appends a string field.
+func (m *protoMessage) String(field int, s string) {
+	m.Bytes(field, []byte(s))
+}
+
+// Message appends an embedded message field.
+func (m *protoMessage) Message(field int, sub protoMessage) {
+	m.Bytes(field, sub)
+}
+
+// onnxGraph builds the GraphProto of an ONNX model.
+type onnxGraph struct {
+	Nodes        []protoMessage     // NodeProto of each operation
+	Initializers []protoMessage     // TensorProto of each constant
+	scalars      map[float32]string // names of scalar constants
+	numValues    int                // number of intermediate values
+}
+
+// newONNXGraph creates an empty ONNX graph.
+func newONNXGraph() *onnxGraph {
+	return &onnxGraph{scalars: make(map[float32]string)}
+}
+
+// value returns a new unique name of an intermediate value.
+func (g *onnxGraph) value() string {
+	g.numValues++
+	return fmt.Sprintf("t%d", g.numValues)
+}
+
+// op adds an operation with the argument type, inputs and attributes, and
+// returns the name of its output. If output is empty, a new name is used.
+func (g *onnxGraph) op(opType, output string, inputs []string,
+	attributes ...protoMessage) string {
+	if output == "" {
+		output = g.value()
+	}
+	var node protoMessage
+	for _, input := range inputs {
+		node.String(1, input)
+	}
+	node.String(2, output)
+	node.String(3, output)
+	node.String(4, opType)
+	for _, attribute := range attributes {
+		node.Message(5, attribute)
+	}
+	g.Nodes = append(g.Nodes, node)
+	return output
+}
+
+// intAttribute returns an AttributeProto of an integer.
+func intAttribute(name string, v int64) protoMessage {
+	var attribute protoMessage
+	attribute.String(1, name)
+	attribute.Int(3, v)
+	attribute.Int(20, 2) // INT
+	return attribute
+}
+
+// tensor adds a constant tensor of the argument element type, shape and raw
+// little-endian data, and returns its name.
+func (g *onnxGraph) tensor(name string, elemType int, dims []int64,
+	raw []byte) string {
+	var tensor protoMessage
+	for _, dim := range dims {
+		tensor.Int(1, dim)
+	}
+	tensor.Int(2, int64(elemType))
+	tensor.String(8, name)
+	tensor.Bytes(9, raw)
+	g.Initializers = append(g.Initializers, tensor)
+	return name
+}
+
+// floats adds a constant float tensor of the argument shape.
+func (g *onnxGraph) floats(name string, dims []int64, data []float64) string {
+	raw := make([]byte, 0, 4*len(data))
+	for _, v := range data {
+		raw = binary.LittleEndian.AppendUint32(raw,
+			math.Float32bits(float32(v)))
+	}
+	return g.tensor(name, onnxFloat, dims, raw)
+}
+
+// scalar returns the name of a scalar float constant, adding it if needed.
+func (g *onnxGraph) scalar(v float64) string {
+	if name, ok := g.scalars[float32(v)]; ok {
+		return name
+	}
+	name := g.floats(fmt.Sprintf("c%d", len(g.scalars)), nil, []float64{v})
+	g.scalars[float32(v)] = name
+	return name
+}
+
+// clip adds operations that multiply x by the argument scale, and clip it to
+// [min, max], as in the activation functions in aFuncSet.
+func (g *onnxGraph) clip(x string, scale, min, max float64) string {
+	if scale != 1.0 {
+		x = g.op("Mul", "", []string{x, g.scalar(scale)})
+	}
+	return g.op("Clip", "", []string{x, g.scalar(min), g.scalar(max)})
+}
+
+// activate adds operations of the activation function of the argument name
+// applied to x, with the argument output, lowering the activation functions
+// in aFuncSet to primitive operations.
+func (g *onnxGraph) activate(afunc, x, output string) (string, error) {
+	switch afunc {
+	case "identity":
+		return g.op("Identity", output, []string{x}), nil
+	case "sigmoid":
+		return g.op("Sigmoid", output, []string{g.clip(x, 5.0, -60.0, 60.0)}), nil
+	case "tanh":
+		return g.op("Tanh", output, []string{g.clip(x, 2.5, -60.0, 60.0)}), nil
+	case "relu":
+		// relu in aFuncSet is 1 for negative inputs
+		positive := g.op("GreaterOrEqual", "", []string{x, g.scalar(0.0)})
+		return g.op("Where", output,
+			[]string{positive, x, g.scalar(1.0)}), nil
+	case "elu":
+		positive := g.op("GreaterOrEqual", "", []string{x, g.scalar(0.0)})
+		exp := g.op("Exp", "", []string{g.clip(x, 2.5, -60.0, 60.0)})
+		negative := g.op("Sub", "", []string{exp, g.scalar(1.0)})
+		return g.op("Where", output,
+			[]string{positive, x, negative}), nil
+	case "abs":
+		return g.op("Abs", output, []string{x}), nil
+	case "sine":
+		return g.op("Sin", output, []string{x}), nil
+	case "gaussian":
+		// gaussian with mu = 0 and sigma = 1
+		x = g.clip(x, 1.0, -3.4, 3.4)
+		square := g.op("Mul", "", []string{x, x})
+		exp := g.op("Exp", "", []string{
+			g.op("Mul", "", []string{square, g.scalar(-0.5)})})
+		return g.op("Mul", output,
+			[]string{exp, g.scalar(1.0 / math.Sqrt(2.0*math.Pi))}), nil
+	}
+	return "", fmt.Errorf("activation function %s is not supported", afunc)
+}
+
+// valueInfo returns a ValueInfoProto of a float matrix with a dynamic batch
+// size and the argument number of columns.
+func valueInfo(name string, cols int) protoMessage {
+	var batch, col protoMessage
+	batch.String(2, "N")
+	col.Int(1, int64(cols))
+	var shape protoMessage
+	shape.Message(1, batch)
+	shape.Message(1, col)
+
+	var tensor protoMessage
+	tensor.Int(1, onnxFloat)
+	tensor.Message(2, shape)
+	var typ protoMessage
+	typ.Message(1, tensor)
+
+	var info protoMessage
+	info.String(1, name)
+	info.Message(2, typ)
+	return info
+}
+
+// ONNX returns the compiled graph of this DPPN as a serialized ONNX model.
+// The model maps an N x NumInputs float matrix named "input", encoded as in
+// encodeInputs, to an N x NumOutputs float matrix named "output". Weights
+// are stored in single precision.
+func (n *DPPN) ONNX() ([]byte, error) {
+	p := n.program()
+	g := newONNXGraph()
+
+	names := make(map[int]string)
+	for i, id := range p.Inputs {
+		index := make([]byte, 8)
+		binary.LittleEndian.PutUint64(index, uint64(i))
+		indices := g.tensor(fmt.Sprintf("index_%d", id), onnxInt64,
+			[]int64{1}, index)
+		names[id] = g.op("Gather", fmt.Sprintf("node_%d", id),
+			[]string{"input", indices}, intAttribute("axis", 1))
+	}
+
+	for _, node := range p.Nodes {
+		output := fmt.Sprintf("node_%d", node.ID)
+		if len(node.Terms) == 0 {
+			// as in the DPPN, a node without inputs has a signal of zero
+			zeros := g.floats(fmt.Sprintf("weights_%d", node.ID),
+				[]int64{int64(len(p.Inputs)), 1}, make([]float64, len(p.Inputs)))
+			names[node.ID] = g.op("MatMul", output, []string{"input", zeros})
+			continue
+		}
+
+		inputs := make([]string, len(node.Terms))
+		weights := make([]float64, len(node.Terms))
+		for i, term := range node.Terms {
+			inputs[i] = names[term.Input]
+			weights[i] = term.Weight
+		}
+		concat := g.op("Concat", "", inputs, intAttribute("axis", 1))
+		w := g.floats(fmt.Sprintf("weights_%d", node.ID),
+			[]int64{int64(len(weights)), 1}, weights)
+		sum := g.op("MatMul", "", []string{concat, w})
+
+		name, err := g.activate(node.AFunc, sum, output)
+		if err != nil {
+			return nil, err
+		}
+		names[node.ID] = name
+	}
+
+	outputs := make([]string, len(p.Outputs))
+	for i, id := range p.Outputs {
+		outputs[i] = names[id]
+	}
+	g.op("Concat", "output", outputs, intAttribute("axis", 1))
+
+	var graph protoMessage
+	for _, node := range g.Nodes {
+		graph.Message(1, node)
+	}
+	graph.String(2, fmt.Sprintf("dppn_%d", n.ID))
+	for _, tensor := range g.Initializers {
+		graph.Message(5, tensor)
+	}
+	graph.Message(11, valueInfo("input", n.NumInputs))
+	graph.Message(12, valueInfo("output", n.NumOutputs))
+
+	var opset protoMessage
+	opset.String(1, "")
+	opset.Int(2, onnxOpset)
+
+	var model protoMessage
+	model.Int(1, onnxIRVersion)
+	model.String(2, "imagen")
+	model.Message(7, graph)
+	model.Message(8, opset)
+	return model, nil
+}
+
+// onnx exports an exported genome as an ONNX model.
+func onnx(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: imagen onnx [genome].txt [output].onnx")
+	}
+
+	g, err := LoadGenome(args[0], 0)
+	if err != nil {
+		return err
+	}
+	d, err := NewDPPN(g, 1)
+	if err != nil {
+		return err
+	}
+	model, err := d.ONNX()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(args[1], model, 0644)
+}
@@ -0,0 +1,419 @@
+/*
+
+
+onnx_test.go tests for ONNX model export, with an evaluator of exported models.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/gonum/matrix/mat64"
+)
+
+// protoField is a decoded field of a protocol buffers message.
+type protoField struct {
+	Varint uint64 // value of a varint or fixed32 field
+	Bytes  []byte // value of a length-delimited field
+}
+
+// decodeProto decodes the fields of a protocol buffers message.
+func decodeProto(b []byte) (map[int][]protoField, error) {
+	fields := make(map[int][]protoField)
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, fmt.Errorf("invalid field key")
+		}
+		b = b[n:]
+		field := int(key >> 3)
+		switch key & 7 {
+		case 0:
+			v, n := binary.Uvarint(b)
+			if n <= 0 {
+				return nil, fmt.Errorf("invalid varint in field %d", field)
+			}
+			b = b[n:]
+			fields[field] = append(fields[field], protoField{Varint: v})
+		case 2:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				return nil, fmt.Errorf("invalid length in field %d", field)
+			}
+			fields[field] = append(fields[field],
+				protoField{Bytes: b[n : n+int(l)]})
+			b = b[n+int(l):]
+		case 5:
+			if len(b) < 4 {
+				return nil, fmt.Errorf("invalid fixed32 in field %d", field)
+			}
+			fields[field] = append(fields[field],
+				protoField{Varint: uint64(binary.LittleEndian.Uint32(b))})
+			b = b[4:]
+		default:
+			return nil, fmt.Errorf("unsupported wire type in field %d", field)
+		}
+	}
+	return fields, nil
+}
+
+// onnxTensor is a matrix value in the evaluator; scalars are 1 x 1, and
+// 1-D tensors are 1 x n.
+type onnxTensor struct {
+	Rows, Cols int
+	Data       []float64
+}
+
+// at returns the element at (i, j), broadcasting a scalar.
+func (t *onnxTensor) at(i, j int) float64 {
+	if t.Rows == 1 && t.Cols == 1 {
+		return t.Data[0]
+	}
+	return t.Data[i*t.Cols+j]
+}
+
+// onnxNode is a decoded NodeProto.
+type onnxNode struct {
+	OpType string
+	Inputs []string
+	Output string
+	Axis   int
+}
+
+// onnxModel is a decoded ONNX model, as exported by DPPN.ONNX.
+type onnxModel struct {
+	Opset   int
+	Inputs  []string
+	Outputs []string
+	Nodes   []onnxNode
+	Values  map[string]*onnxTensor
+}
+
+// decodeONNX decodes an ONNX model exported by DPPN.ONNX.
+func decodeONNX(b []byte) (*onnxModel, error) {
+	model, err := decodeProto(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(model[7]) != 1 || len(model[8]) != 1 {
+		return nil, fmt.Errorf("model has no graph or opset")
+	}
+	opset, err := decodeProto(model[8][0].Bytes)
+	if err != nil {
+		return nil, err
+	}
+	graph, err := decodeProto(model[7][0].Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &onnxModel{
+		Opset:  int(opset[2][0].Varint),
+		Values: make(map[string]*onnxTensor),
+	}
+	for _, f := range graph[1] {
+		node, err := decodeProto(f.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		n := onnxNode{
+			OpType: string(node[4][0].Bytes),
+			Output: string(node[2][0].Bytes),
+		}
+		for _, input := range node[1] {
+			n.Inputs = append(n.Inputs, string(input.Bytes))
+		}
+		for _, a := range node[5] {
+			attribute, err := decodeProto(a.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			if string(attribute[1][0].Bytes) == "axis" {
+				n.Axis = int(int64(attribute[3][0].Varint))
+			}
+		}
+		m.Nodes = append(m.Nodes, n)
+	}
+	for _, f := range graph[5] {
+		tensor, err := decodeProto(f.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		t := &onnxTensor{Rows: 1, Cols: 1}
+		dims := tensor[1]
+		switch len(dims) {
+		case 1:
+			t.Cols = int(dims[0].Varint)
+		case 2:
+			t.Rows, t.Cols = int(dims[0].Varint), int(dims[1].Varint)
+		}
+		raw := tensor[9][0].Bytes
+		switch tensor[2][0].Varint {
+		case onnxFloat:
+			for i := 0; i < len(raw); i += 4 {
+				t.Data = append(t.Data, float64(math.Float32frombits(
+					binary.LittleEndian.Uint32(raw[i:]))))
+			}
+		case onnxInt64:
+			for i := 0; i < len(raw); i += 8 {
+				t.Data = append(t.Data,
+					float64(int64(binary.LittleEndian.Uint64(raw[i:]))))
+			}
+		}
+		m.Values[string(tensor[8][0].Bytes)] = t
+	}
+	for _, f := range graph[11] {
+		info, err := decodeProto(f.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		m.Inputs = append(m.Inputs, string(info[1][0].Bytes))
+	}
+	for _, f := range graph[12] {
+		info, err := decodeProto(f.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		m.Outputs = append(m.Outputs, string(info[1][0].Bytes))
+	}
+	return m, nil
+}
+
+// elementwise returns the result of an elementwise function of tensors with
+// broadcasting of scalars.
+func elementwise(fn func(args []float64) float64,
+	tensors ...*onnxTensor) *onnxTensor {
+	out := &onnxTensor{Rows: 1, Cols: 1}
+	for _, t := range tensors {
+		if t.Rows*t.Cols > out.Rows*out.Cols {
+			out.Rows, out.Cols = t.Rows, t.Cols
+		}
+	}
+	out.Data = make([]float64, out.Rows*out.Cols)
+	args := make([]float64, len(tensors))
+	for i := 0; i < out.Rows; i++ {
+		for j := 0; j < out.Cols; j++ {
+			for k, t := range tensors {
+				args[k] = t.at(i, j)
+			}
+			out.Data[i*out.Cols+j] = fn(args)
+		}
+	}
+	return out
+}
+
+// Run evaluates the model for the argument input.
+func (m *onnxModel) Run(input *onnxTensor) (*onnxTensor, error) {
+	values := make(map[string]*onnxTensor)
+	for name, t := range m.Values {
+		values[name] = t
+	}
+	values[m.Inputs[0]] = input
+
+	for _, n := range m.Nodes {
+		args := make([]*onnxTensor, len(n.Inputs))
+		for i, name := range n.Inputs {
+			if args[i] = values[name]; args[i] == nil {
+				return nil, fmt.Errorf("%s uses undefined %s", n.Output, name)
+			}
+		}
+
+		var out *onnxTensor
+		unary := func(fn func(float64) float64) *onnxTensor {
+			return elementwise(func(v []float64) float64 {
+				return fn(v[0])
+			}, args[0])
+		}
+		switch n.OpType {
+		case "Gather":
+			x, indices := args[0], args[1]
+			out = &onnxTensor{Rows: x.Rows, Cols: len(indices.Data)}
+			for i := 0; i < x.Rows; i++ {
+				for _, j := range indices.Data {
+					out.Data = append(out.Data, x.at(i, int(j)))
+				}
+			}
+		case "Concat":
+			out = &onnxTensor{Rows: args[0].Rows}
+			for _, t := range args {
+				out.Cols += t.Cols
+			}
+			for i := 0; i < out.Rows; i++ {
+				for _, t := range args {
+					out.Data = append(out.Data, t.Data[i*t.Cols:(i+1)*t.Cols]...)
+				}
+			}
+		case "MatMul":
+			a, b := args[0], args[1]
+			if a.Cols != b.Rows {
+				return nil, fmt.Errorf("%s multiplies %dx%d by %dx%d",
+					n.Output, a.Rows, a.Cols, b.Rows, b.Cols)
+			}
+			out = &onnxTensor{Rows: a.Rows, Cols: b.Cols,
+				Data: make([]float64, a.Rows*b.Cols)}
+			for i := 0; i < a.Rows; i++ {
+				for j := 0; j < b.Cols; j++ {
+					for k := 0; k < a.Cols; k++ {
+						out.Data[i*b.Cols+j] += a.at(i, k) * b.at(k, j)
+					}
+				}
+			}
+		case "Mul":
+			out = elementwise(func(v []float64) float64 {
+				return v[0] * v[1]
+			}, args...)
+		case "Sub":
+			out = elementwise(func(v []float64) float64 {
+				return v[0] - v[1]
+			}, args...)
+		case "Clip":
+			out = elementwise(func(v []float64) float64 {
+				return math.Max(v[1], math.Min(v[2], v[0]))
+			}, args...)
+		case "GreaterOrEqual":
+			out = elementwise(func(v []float64) float64 {
+				if v[0] >= v[1] {
+					return 1.0
+				}
+				return 0.0
+			}, args...)
+		case "Where":
+			out = elementwise(func(v []float64) float64 {
+				if v[0] != 0.0 {
+					return v[1]
+				}
+				return v[2]
+			}, args...)
+		case "Identity":
+			out = unary(func(x float64) float64 { return x })
+		case "Sigmoid":
+			out = unary(func(x float64) float64 {
+				return 1.0 / (1.0 + math.Exp(-x))
+			})
+		case "Tanh":
+			out = unary(math.Tanh)
+		case "Exp":
+			out = unary(math.Exp)
+		case "Abs":
+			out = unary(math.Abs)
+		case "Sin":
+			out = unary(math.Sin)
+		default:
+			return nil, fmt.Errorf("unsupported operation %s", n.OpType)
+		}
+		values[n.Output] = out
+	}
+
+	out, ok := values[m.Outputs[0]]
+	if !ok {
+		return nil, fmt.Errorf("output %s is undefined", m.Outputs[0])
+	}
+	return out, nil
+}
+
+func TestDPPNONNX(t *testing.T) {
+	rand.Seed(0)
+
+	const batchSize = 16
+	for i := 0; i < 10; i++ {
+		g0 := NewGenome(0, 4, 4, 3)
+		g1 := NewGenome(1, 4, 4, 3)
+		for j := 0; j < 20; j++ {
+			g0.Mutate(0.5, 0.5)
+			g1.Mutate(0.5, 0.5)
+		}
+		g0.Crossover(g1)
+
+		n, err := NewDPPN(g0, batchSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := n.ONNX()
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := decodeONNX(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if m.Opset != onnxOpset {
+			t.Errorf("model has opset %d, expected %d", m.Opset, onnxOpset)
+		}
+		if len(m.Inputs) != 1 || m.Inputs[0] != "input" ||
+			len(m.Outputs) != 1 || m.Outputs[0] != "output" {
+			t.Errorf("model has inputs %v and outputs %v", m.Inputs, m.Outputs)
+		}
+		defined := make(map[string]bool)
+		for name := range m.Values {
+			defined[name] = true
+		}
+		defined["input"] = true
+		for _, node := range m.Nodes {
+			for _, input := range node.Inputs {
+				if !defined[input] {
+					t.Fatalf("%s uses %s before it is defined",
+						node.Output, input)
+				}
+			}
+			defined[node.Output] = true
+		}
+
+		inputs := make([]float64, batchSize*4)
+		for j := 0; j < batchSize; j++ {
+			encodeInputs("cartesian", float64(j%4), float64(j/4), 4.0, 4.0,
+				0.0, nil, inputs[j*4:(j+1)*4])
+		}
+		expected, err := n.FeedForward(mat64.NewDense(batchSize, 4, inputs))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := m.Run(&onnxTensor{Rows: batchSize, Cols: 4, Data: inputs})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.Rows != batchSize || out.Cols != 3 {
+			t.Fatalf("model output is %dx%d", out.Rows, out.Cols)
+		}
+		for j, v := range expected.RawMatrix().Data {
+			// weights are stored in single precision
+			if diff := math.Abs(v - out.Data[j]); diff > 1e-4*math.Max(1.0, math.Abs(v)) {
+				t.Errorf("model output %d is %g, expected %g", j, out.Data[j], v)
+			}
+		}
+	}
+}
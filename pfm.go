@@ -0,0 +1,108 @@
+/*
+
+
+pfm.go implementation of floating-point images and PFM output.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"os"
+)
+
+// FloatImage is an image of colors, which are not premultiplied by alpha,
+// and alpha, in floating point, which are neither clamped nor quantized.
+type FloatImage struct {
+	Pix  []float64       // R, G, B and A of each pixel, in rows from the top
+	Rect image.Rectangle // bounds of the image
+}
+
+// NewFloatImage creates a new transparent floating-point image of the
+// argument bounds.
+func NewFloatImage(r image.Rectangle) *FloatImage {
+	return &FloatImage{
+		Pix:  make([]float64, 4*r.Dx()*r.Dy()),
+		Rect: r,
+	}
+}
+
+// PixOffset returns the index of the first element of Pix of the pixel at
+// (x, y).
+func (p *FloatImage) PixOffset(x, y int) int {
+	return 4 * ((y-p.Rect.Min.Y)*p.Rect.Dx() + x - p.Rect.Min.X)
+}
+
+// WritePFM writes the argument image as a color Portable FloatMap (PFM) in
+// little-endian single precision, whose rows are from the bottom. PFM has no
+// alpha, so it is discarded.
+func WritePFM(w io.Writer, img *FloatImage) error {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	bw := bufio.NewWriter(w)
+	// a negative scale denotes little-endian values
+	if _, err := fmt.Fprintf(bw, "PF\n%d %d\n-1.0\n", width, height); err != nil {
+		return err
+	}
+
+	row := make([]byte, 12*width)
+	for y := img.Rect.Max.Y - 1; y >= img.Rect.Min.Y; y-- {
+		for x := 0; x < width; x++ {
+			i := img.PixOffset(img.Rect.Min.X+x, y)
+			for c := 0; c < 3; c++ {
+				binary.LittleEndian.PutUint32(row[12*x+4*c:],
+					math.Float32bits(float32(img.Pix[i+c])))
+			}
+		}
+		if _, err := bw.Write(row); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// writePFM writes the argument image to a PFM file.
+func writePFM(filename string, img *FloatImage) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := WritePFM(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
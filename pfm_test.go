@@ -0,0 +1,85 @@
+/*
+
+
+pfm_test.go tests for PFM output.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"math"
+	"testing"
+)
+
+func TestWritePFM(t *testing.T) {
+	img := NewFloatImage(image.Rect(0, 0, 3, 2))
+	for i := range img.Pix {
+		img.Pix[i] = float64(i) - 2.5
+	}
+
+	var buf bytes.Buffer
+	if err := WritePFM(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	var width, height int
+	var scale float64
+	n, err := fmt.Fscanf(&buf, "PF\n%d %d\n%f\n", &width, &height, &scale)
+	if err != nil || n != 3 {
+		t.Fatalf("invalid header: %v", err)
+	}
+	if width != 3 || height != 2 || scale >= 0.0 {
+		t.Fatalf("header is %d x %d with scale %f", width, height, scale)
+	}
+
+	data := buf.Bytes()
+	if len(data) != 4*3*width*height {
+		t.Fatalf("PFM has %d bytes of pixels, expected %d", len(data),
+			4*3*width*height)
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			// rows are from the bottom
+			i := img.PixOffset(x, height-1-y)
+			for c := 0; c < 3; c++ {
+				bits := binary.LittleEndian.Uint32(data[4*(3*(y*width+x)+c):])
+				if v := math.Float32frombits(bits); float64(v) != img.Pix[i+c] {
+					t.Errorf("component %d of pixel (%d, %d) is %f, "+
+						"expected %f", c, x, height-1-y, v, img.Pix[i+c])
+				}
+			}
+		}
+	}
+}
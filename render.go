@@ -0,0 +1,553 @@
+/*
+
+
+render.go implementation of rendering of DPPN into images.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"fmt"
+	"github.com/gonum/matrix/mat64"
+	"image"
+	"image/color"
+	"math"
+	"runtime"
+	"sync"
+)
+
+// RenderOptions contains the parameters for rendering a genome's DPPN into
+// an image.
+type RenderOptions struct {
+	Width        int        // width of the image in pixels
+	Height       int        // height of the image in pixels
+	DomainWidth  float64    // width of the coordinate domain (training image)
+	DomainHeight float64    // height of the coordinate domain (training image)
+	Zoom         float64    // zoom factor about the center of the domain
+	PanX         float64    // horizontal pan in domain coordinates
+	PanY         float64    // vertical pan in domain coordinates
+	Encoding     string     // input encoding of coordinates (cartesian, torus)
+	Symmetry     Symmetry   // symmetry folding coordinates before encoding
+	ColorSpace   ColorSpace // color space of the outputs, and their alpha
+	Rotation     float64    // clockwise rotation about the center in radians
+	Time         float64    // time input, if the genome has one
+	Latent       []float64  // latent inputs following the time input
+	Samples      int        // samples per pixel along each axis (N x N)
+	Jitter       bool       // jitter samples within their grid cells
+	Filter       string     // reconstruction filter of samples (box, tent)
+	Warp         *Genome    // genome displacing the coordinates, if any
+	WarpStrength float64    // scale of the displacement by the warp genome
+	BitDepth     int        // bits per channel of PNG images (8, 16)
+}
+
+// NewRenderOptions creates new render options that render the whole
+// coordinate domain of the argument size, at the same size.
+func NewRenderOptions(width, height int) *RenderOptions {
+	return &RenderOptions{
+		Width:        width,
+		Height:       height,
+		DomainWidth:  float64(width),
+		DomainHeight: float64(height),
+		Zoom:         1.0,
+		Encoding:     "cartesian",
+		Samples:      1,
+		Filter:       "box",
+		WarpStrength: 1.0,
+		BitDepth:     8,
+	}
+}
+
+// renderFilter is a reconstruction filter for supersampling, given by the
+// radius of its support in pixels, and its weight at an offset from the
+// pixel.
+type renderFilter struct {
+	Radius float64
+	Weight func(dx, dy float64) float64
+}
+
+// renderFilters contains the reconstruction filters by name.
+var renderFilters = map[string]*renderFilter{
+	"box": {0.5, func(dx, dy float64) float64 {
+		return 1.0
+	}},
+	"tent": {1.0, func(dx, dy float64) float64 {
+		return (1.0 - math.Abs(dx)) * (1.0 - math.Abs(dy))
+	}},
+}
+
+// renderSample is a sample of a pixel, at an offset from the pixel's
+// coordinate, with its filter weight.
+type renderSample struct {
+	DX, DY float64
+	Weight float64
+}
+
+// Validate returns an error if the viewport, supersampling or warp options
+// are invalid.
+func (o *RenderOptions) Validate() error {
+	if o.Zoom <= 0.0 {
+		return fmt.Errorf("invalid zoom %f", o.Zoom)
+	}
+	if !inputEncodings[o.Encoding] {
+		return fmt.Errorf("unknown input encoding %s", o.Encoding)
+	}
+	if _, err := ParseSymmetry(o.Symmetry.String()); err != nil {
+		return err
+	}
+	if o.ColorSpace.Name != "" && !colorSpaces[o.ColorSpace.Name] {
+		return fmt.Errorf("unknown color space %s", o.ColorSpace.Name)
+	}
+	if o.BitDepth != 8 && o.BitDepth != 16 {
+		return fmt.Errorf("invalid bit depth %d", o.BitDepth)
+	}
+	if o.Samples < 1 {
+		return fmt.Errorf("invalid number of samples %d", o.Samples)
+	}
+	if _, ok := renderFilters[o.Filter]; !ok {
+		return fmt.Errorf("unknown filter %s", o.Filter)
+	}
+	if o.Warp != nil {
+		if o.Warp.NumOutputs < 2 {
+			return fmt.Errorf("warp genome must have at least 2 outputs")
+		}
+		if _, err := NewDPPN(o.Warp, 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jitter returns a pseudorandom number in [0, 1) determined by a pixel and
+// the index of a value for the pixel, so that jittered renders are the same
+// whichever part of an image is rendered, and by whichever worker.
+func jitter(px, py, i int) float64 {
+	// SplitMix64 of the pixel and the index
+	z := uint64(px)*0x9e3779b97f4a7c15 ^ uint64(py)*0xbf58476d1ce4e5b9 ^
+		uint64(i)*0x94d049bb133111eb
+	z += 0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	z ^= z >> 31
+	return float64(z>>11) / (1 << 53)
+}
+
+// samples returns the samples of the argument pixel: an N x N grid spanning
+// the support of the filter, centered on the pixel's coordinate, with each
+// sample jittered within its grid cell if Jitter is set. A single sample
+// without jitter is at the pixel's coordinate, as in training.
+func (o *RenderOptions) samples(px, py int) []renderSample {
+	filter, ok := renderFilters[o.Filter]
+	if !ok {
+		filter = renderFilters["box"]
+	}
+	n := o.Samples
+	if n < 1 {
+		n = 1
+	}
+
+	offset := func(i, k int) float64 {
+		u := 0.5
+		if o.Jitter {
+			u = jitter(px, py, k)
+		}
+		return ((float64(i)+u)/float64(n)*2.0 - 1.0) * filter.Radius
+	}
+
+	samples := make([]renderSample, 0, n*n)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			k := 2 * (i*n + j)
+			dx, dy := offset(j, k), offset(i, k+1)
+			samples = append(samples,
+				renderSample{dx, dy, filter.Weight(dx, dy)})
+		}
+	}
+	return samples
+}
+
+// Coordinates maps the argument pixel position to the coordinate domain,
+// applying the affine transform of the viewport: the zoom and the rotation
+// about the center of the domain, followed by the pan.
+func (o *RenderOptions) Coordinates(px, py float64) (float64, float64) {
+	cx, cy := o.DomainWidth/2.0, o.DomainHeight/2.0
+	x := px*o.DomainWidth/float64(o.Width) - cx
+	y := py*o.DomainHeight/float64(o.Height) - cy
+	if o.Rotation != 0.0 {
+		sin, cos := math.Sincos(-o.Rotation)
+		x, y = cos*x-sin*y, sin*x+cos*y
+	}
+	return x/o.Zoom + cx + o.PanX, y/o.Zoom + cy + o.PanY
+}
+
+// inputEncodings contains the names of the input encodings.
+var inputEncodings = map[string]bool{
+	"cartesian": true,
+	"torus":     true,
+}
+
+// numEncodedInputs returns the number of inputs that encode a coordinate with
+// the argument input encoding in encodeInputs, which are followed by the time.
+func numEncodedInputs(encoding string) int {
+	if encoding == "torus" {
+		return 5
+	}
+	return 4
+}
+
+// encodeInputs encodes a coordinate (x, y) in a domain of the argument size
+// into the DPPN's inputs, with the argument input encoding:
+//
+//   - cartesian (or empty): the scaled coordinate, its scaled distance from
+//     the center of the domain, and a bias.
+//   - torus: the sine and cosine of x and y with the domain's width and height
+//     as their periods, and a bias, so that the pattern tiles seamlessly.
+//
+// Any remaining inputs are set to the time and the latent inputs, or zero if
+// there are not enough of them.
+func encodeInputs(encoding string, x, y, width, height, t float64,
+	latent []float64, inputs []float64) {
+	var coords []float64
+	switch encoding {
+	case "torus":
+		sx, cx := math.Sincos(2.0 * math.Pi * x / width)
+		sy, cy := math.Sincos(2.0 * math.Pi * y / height)
+		coords = []float64{sx, cx, sy, cy, 1.0, t}
+	default:
+		d := math.Sqrt((x-width/2.0)*(x-width/2.0) +
+			(y-height/2.0)*(y-height/2.0))
+		coords = []float64{x * 0.1, y * 0.1, d * 0.1, 1.0, t}
+	}
+
+	for i := range inputs {
+		switch {
+		case i < len(coords):
+			inputs[i] = coords[i]
+		case i-len(coords) < len(latent):
+			inputs[i] = latent[i-len(coords)]
+		default:
+			inputs[i] = 0.0
+		}
+	}
+}
+
+// renderBatchSize is the number of samples each rendering worker feeds
+// through its DPPN at once.
+const renderBatchSize = 1024
+
+// renderWorker is a worker of a renderer, with its own DPPN and buffers.
+type renderWorker struct {
+	dppn   *DPPN         // DPPN with a batch size of renderBatchSize
+	inputs *mat64.Dense  // input batch, reused for every batch
+	coords []float64     // coordinates of the samples in the batch
+	warp   *DPPN         // DPPN of the warp genome, if any
+	warped *Genome       // warp genome compiled into warp
+	warpIn *mat64.Dense  // input batch of the warp genome
+	rgba   []float64     // weighted sum of the samples of each pixel in a job
+	total  []float64     // total weight of the samples of each pixel in a job
+	batch  []renderBatch // samples in the current batch
+}
+
+// renderBatch is a sample in a batch, with the pixel it belongs to.
+type renderBatch struct {
+	Pixel  int
+	Weight float64
+}
+
+// Renderer renders a genome's DPPN into images, rendering rows of an image
+// concurrently. Each worker has its own DPPN, and feeds the samples of its
+// rows through it in large batches.
+type Renderer struct {
+	NumInputs  int             // number of inputs of the DPPN
+	NumOutputs int             // number of outputs of the DPPN
+	workers    []*renderWorker // workers, one per goroutine
+}
+
+// NewRenderer creates a new renderer of the argument genome, with the
+// argument number of workers, or one per CPU if it is not positive.
+func NewRenderer(g *Genome, numWorkers int) (*Renderer, error) {
+	if numWorkers <= 0 {
+		numWorkers = runtime.GOMAXPROCS(0)
+	}
+
+	r := &Renderer{
+		NumInputs:  g.NumInputs,
+		NumOutputs: g.NumOutputs,
+		workers:    make([]*renderWorker, numWorkers),
+	}
+	for i := range r.workers {
+		n, err := NewDPPN(g, renderBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		r.workers[i] = &renderWorker{
+			dppn:   n,
+			inputs: mat64.NewDense(renderBatchSize, g.NumInputs, nil),
+			coords: make([]float64, 2*renderBatchSize),
+			batch:  make([]renderBatch, 0, renderBatchSize),
+		}
+	}
+	return r, nil
+}
+
+// setWarp compiles the argument warp genome, if it is not compiled yet.
+func (w *renderWorker) setWarp(g *Genome) {
+	if g == w.warped {
+		return
+	}
+	w.warp, w.warped = nil, g
+	if g != nil {
+		w.warp, _ = NewDPPN(g, renderBatchSize)
+		w.warpIn = mat64.NewDense(renderBatchSize, g.NumInputs, nil)
+	}
+}
+
+// flush displaces the coordinates of the current batch by the warp genome, if
+// any, feeds the batch through the worker's DPPN, and accumulates the outputs
+// into the pixels of the rows.
+func (w *renderWorker) flush(opts *RenderOptions) {
+	if len(w.batch) == 0 {
+		return
+	}
+
+	if w.warp != nil {
+		raw := w.warpIn.RawMatrix()
+		for i := range w.batch {
+			encodeInputs(opts.Encoding, w.coords[2*i], w.coords[2*i+1],
+				opts.DomainWidth, opts.DomainHeight, opts.Time, opts.Latent,
+				raw.Data[i*raw.Stride:i*raw.Stride+raw.Cols])
+		}
+		outputVec, _ := w.warp.FeedForward(w.warpIn)
+		for i := range w.batch {
+			w.coords[2*i] += opts.WarpStrength * outputVec.At(i, 0)
+			w.coords[2*i+1] += opts.WarpStrength * outputVec.At(i, 1)
+		}
+	}
+
+	inputs := w.inputs.RawMatrix()
+	cx := gridCenter(opts.DomainWidth, opts.Width)
+	cy := gridCenter(opts.DomainHeight, opts.Height)
+	for i := range w.batch {
+		x, y := opts.Symmetry.Fold(w.coords[2*i], w.coords[2*i+1], cx, cy)
+		encodeInputs(opts.Encoding, x, y,
+			opts.DomainWidth, opts.DomainHeight, opts.Time, opts.Latent,
+			inputs.Data[i*inputs.Stride:i*inputs.Stride+inputs.Cols])
+	}
+	outputVec, _ := w.dppn.FeedForward(w.inputs)
+	outputs := outputVec.RawMatrix().Data
+	numOutputs := w.dppn.NumOutputs
+
+	// samples are accumulated premultiplied by alpha, so that transparent
+	// samples do not contribute their color
+	for i, s := range w.batch {
+		r, g, b, a := opts.ColorSpace.RGBA(
+			outputs[i*numOutputs : (i+1)*numOutputs])
+		w.rgba[4*s.Pixel] += s.Weight * r * a
+		w.rgba[4*s.Pixel+1] += s.Weight * g * a
+		w.rgba[4*s.Pixel+2] += s.Weight * b * a
+		w.rgba[4*s.Pixel+3] += s.Weight * a
+		w.total[s.Pixel] += s.Weight
+	}
+	w.batch = w.batch[:0]
+}
+
+// pixelFunc sets a pixel of a rendered image, given its color premultiplied
+// by alpha, and its alpha, which are not clamped.
+type pixelFunc func(x, y int, r, g, b, a float64)
+
+// rows renders the rows in [y0, y1) of the argument rectangle, and sets
+// their pixels.
+func (w *renderWorker) rows(rect image.Rectangle, y0, y1 int,
+	opts *RenderOptions, samples []renderSample, set pixelFunc) {
+	x0, x1 := rect.Min.X, rect.Max.X
+	width := x1 - x0
+	numPixels := (y1 - y0) * width
+	if cap(w.total) < numPixels {
+		w.rgba = make([]float64, 4*numPixels)
+		w.total = make([]float64, numPixels)
+	}
+	w.rgba, w.total = w.rgba[:4*numPixels], w.total[:numPixels]
+	for i := range w.rgba {
+		w.rgba[i] = 0.0
+	}
+	for i := range w.total {
+		w.total[i] = 0.0
+	}
+
+	w.setWarp(opts.Warp)
+	for py := y0; py < y1; py++ {
+		for px := x0; px < x1; px++ {
+			if opts.Jitter {
+				samples = opts.samples(px, py)
+			}
+			pixel := (py-y0)*width + px - x0
+			for _, s := range samples {
+				i := len(w.batch)
+				w.coords[2*i], w.coords[2*i+1] = opts.Coordinates(
+					float64(px)+s.DX, float64(py)+s.DY)
+				w.batch = append(w.batch, renderBatch{pixel, s.Weight})
+				if len(w.batch) == renderBatchSize {
+					w.flush(opts)
+				}
+			}
+		}
+	}
+	w.flush(opts)
+
+	_, alpha, _ := opts.ColorSpace.Channels(w.dppn.NumOutputs)
+	for py := y0; py < y1; py++ {
+		for px := x0; px < x1; px++ {
+			pixel := (py-y0)*width + px - x0
+			rgba, total := w.rgba[4*pixel:4*pixel+4], w.total[pixel]
+			// without an alpha output, the image is exactly opaque
+			a := 1.0
+			if alpha {
+				a = rgba[3] / total
+			}
+			set(px, py, rgba[0]/total, rgba[1]/total, rgba[2]/total, a)
+		}
+	}
+}
+
+// quantize returns the argument color, premultiplied by alpha, and alpha as
+// integers up to max, clamped to [0, 1] and the color to [0, alpha].
+func quantize(r, g, b, a, max float64) (float64, float64, float64, float64) {
+	a = clamp01(a)
+	clamp := func(v float64) float64 {
+		return math.Floor(math.Max(0.0, math.Min(a, v)) * max)
+	}
+	return clamp(r), clamp(g), clamp(b), math.Floor(a * max)
+}
+
+// Render renders an image, given render options. Each pixel is the filtered
+// average of the colors of the DPPN's outputs at its samples, premultiplied
+// by their alpha, if any, and clamped to 8 bits per channel. A renderer
+// renders one image at a time.
+func (r *Renderer) Render(opts *RenderOptions) *image.RGBA {
+	return r.RenderRect(opts, image.Rect(0, 0, opts.Width, opts.Height))
+}
+
+// RenderRect renders the argument rectangle of the image given by render
+// options, so that large images can be rendered a tile at a time.
+func (r *Renderer) RenderRect(opts *RenderOptions,
+	rect image.Rectangle) *image.RGBA {
+	img := image.NewRGBA(rect)
+	r.renderRect(opts, rect, func(x, y int, cr, cg, cb, ca float64) {
+		cr, cg, cb, ca = quantize(cr, cg, cb, ca, 255.0)
+		img.SetRGBA(x, y, color.RGBA{uint8(cr), uint8(cg), uint8(cb),
+			uint8(ca)})
+	})
+	return img
+}
+
+// Render64 renders an image as in Render, with 16 bits per channel.
+func (r *Renderer) Render64(opts *RenderOptions) *image.RGBA64 {
+	img := image.NewRGBA64(image.Rect(0, 0, opts.Width, opts.Height))
+	r.renderRect(opts, img.Rect, func(x, y int, cr, cg, cb, ca float64) {
+		cr, cg, cb, ca = quantize(cr, cg, cb, ca, 65535.0)
+		img.SetRGBA64(x, y, color.RGBA64{uint16(cr), uint16(cg), uint16(cb),
+			uint16(ca)})
+	})
+	return img
+}
+
+// RenderImage renders an image as in Render, with the bit depth of the
+// render options.
+func (r *Renderer) RenderImage(opts *RenderOptions) image.Image {
+	if opts.BitDepth == 16 {
+		return r.Render64(opts)
+	}
+	return r.Render(opts)
+}
+
+// RenderFloat renders an image as in Render, without clamping or quantizing
+// the colors, and without premultiplying them by alpha, so that the DPPN's
+// outputs in rgb are preserved. Supersampled colors are averaged weighted by
+// their alpha, and the colors of transparent pixels are zero.
+func (r *Renderer) RenderFloat(opts *RenderOptions) *FloatImage {
+	img := NewFloatImage(image.Rect(0, 0, opts.Width, opts.Height))
+	r.renderRect(opts, img.Rect, func(x, y int, cr, cg, cb, ca float64) {
+		if ca != 0.0 {
+			cr, cg, cb = cr/ca, cg/ca, cb/ca
+		}
+		i := img.PixOffset(x, y)
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = cr, cg, cb, ca
+	})
+	return img
+}
+
+// renderRect renders the argument rectangle of the image given by render
+// options, and sets its pixels. Rows are rendered concurrently, in jobs of
+// about a batch of samples each.
+func (r *Renderer) renderRect(opts *RenderOptions, rect image.Rectangle,
+	set pixelFunc) {
+	var samples []renderSample
+	if !opts.Jitter {
+		samples = opts.samples(0, 0)
+	}
+
+	rowSamples := rect.Dx() * opts.Samples * opts.Samples
+	rowsPerJob := 1
+	if rowSamples > 0 && rowSamples < renderBatchSize {
+		rowsPerJob = renderBatchSize / rowSamples
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for _, w := range r.workers {
+		wg.Add(1)
+		go func(w *renderWorker) {
+			defer wg.Done()
+			for y0 := range jobs {
+				y1 := y0 + rowsPerJob
+				if y1 > rect.Max.Y {
+					y1 = rect.Max.Y
+				}
+				w.rows(rect, y0, y1, opts, samples, set)
+			}
+		}(w)
+	}
+	for y0 := rect.Min.Y; y0 < rect.Max.Y; y0 += rowsPerJob {
+		jobs <- y0
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// render renders the argument genome's DPPN into an image, given render
+// options.
+func render(g *Genome, opts *RenderOptions) *image.RGBA {
+	r, err := NewRenderer(g, 0)
+	if err != nil {
+		return image.NewRGBA(image.Rect(0, 0, opts.Width, opts.Height))
+	}
+	return r.Render(opts)
+}
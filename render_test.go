@@ -0,0 +1,308 @@
+/*
+
+
+render_test.go tests for rendering.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/gonum/matrix/mat64"
+)
+
+func TestRenderSamples(t *testing.T) {
+	opts := NewRenderOptions(4, 4)
+	samples := opts.samples(1, 2)
+	if len(samples) != 1 || samples[0].DX != 0.0 || samples[0].DY != 0.0 {
+		t.Errorf("single sample is %v, expected the pixel's coordinate", samples)
+	}
+
+	for _, filter := range []string{"box", "tent"} {
+		for _, jitter := range []bool{false, true} {
+			opts.Samples, opts.Filter, opts.Jitter = 3, filter, jitter
+			if err := opts.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			samples := opts.samples(1, 2)
+			if len(samples) != 9 {
+				t.Fatalf("%d samples, expected 9", len(samples))
+			}
+			radius := renderFilters[filter].Radius
+			for _, s := range samples {
+				if math.Abs(s.DX) > radius || math.Abs(s.DY) > radius ||
+					s.Weight < 0.0 {
+					t.Errorf("%s filter has sample %v", filter, s)
+				}
+			}
+		}
+	}
+
+	opts.Filter = "lanczos"
+	if err := opts.Validate(); err == nil {
+		t.Error("unknown filter is valid")
+	}
+}
+
+func TestRenderSupersampled(t *testing.T) {
+	rand.Seed(0)
+
+	g := NewGenome(0, 4, 4, 3)
+	for i := 0; i < 20; i++ {
+		g.Mutate(0.5, 0.5)
+	}
+	r, err := NewRenderer(g, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a single sample per pixel renders as in training
+	img0 := render(g, NewRenderOptions(8, 8))
+	opts := NewRenderOptions(8, 8)
+	opts.Filter = "tent"
+	img1 := r.Render(opts)
+	for i, v := range img0.Pix {
+		if img1.Pix[i] != v {
+			t.Fatalf("single sample render differs at %d", i)
+		}
+	}
+
+	// supersampled renders are reproducible
+	opts.Samples, opts.Jitter = 3, true
+	img0, img1 = r.Render(opts), r.Render(opts)
+	for i, v := range img0.Pix {
+		if img1.Pix[i] != v {
+			t.Fatalf("jittered render differs at %d", i)
+		}
+	}
+}
+
+func TestRendererBatches(t *testing.T) {
+	rand.Seed(0)
+
+	g := NewGenome(0, 4, 4, 3)
+	for i := 0; i < 20; i++ {
+		g.Mutate(0.5, 0.5)
+	}
+	n, err := NewDPPN(g, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// rows longer than a batch, rendered by several workers, match
+	// the DPPN's outputs at each pixel
+	const width, height = 1500, 4
+	r, err := NewRenderer(g, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := r.Render(NewRenderOptions(width, height))
+	inputs := make([]float64, 4)
+	for py := 0; py < height; py++ {
+		for px := 0; px < width; px++ {
+			encodeInputs("cartesian", float64(px), float64(py), width,
+				height, 0.0, nil, inputs)
+			outputs, err := n.FeedForward(mat64.NewDense(1, 4, inputs))
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := img.RGBAAt(px, py)
+			expected := []uint8{uint8(outputs.At(0, 0) * 255.0),
+				uint8(outputs.At(0, 1) * 255.0), uint8(outputs.At(0, 2) * 255.0)}
+			if c.R != expected[0] || c.G != expected[1] || c.B != expected[2] {
+				t.Fatalf("pixel (%d, %d) is %v, expected %v", px, py, c,
+					expected)
+			}
+		}
+	}
+}
+
+func TestRenderAlpha(t *testing.T) {
+	rand.Seed(0)
+
+	g := NewGenome(0, 4, 4, 4)
+	for i := 0; i < 20; i++ {
+		g.Mutate(0.5, 0.5)
+	}
+	n, err := NewDPPN(g, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// colors are premultiplied by the alpha output
+	const width, height = 16, 16
+	opts := NewRenderOptions(width, height)
+	opts.ColorSpace = ColorSpace{Name: "hsv"}
+	img := render(g, opts)
+
+	// floating-point colors are not premultiplied, and outputs in rgb are
+	// preserved
+	r, err := NewRenderer(g, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imgFloat := r.RenderFloat(NewRenderOptions(width, height))
+
+	inputs := make([]float64, 4)
+	for py := 0; py < height; py++ {
+		for px := 0; px < width; px++ {
+			encodeInputs("cartesian", float64(px), float64(py), width,
+				height, 0.0, nil, inputs)
+			outputs, err := n.FeedForward(mat64.NewDense(1, 4, inputs))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, g, b, a := opts.ColorSpace.RGBA(outputs.RawMatrix().Data)
+			expected := color.RGBA{uint8(r * a * 255.0), uint8(g * a * 255.0),
+				uint8(b * a * 255.0), uint8(a * 255.0)}
+			if c := img.RGBAAt(px, py); c != expected {
+				t.Fatalf("pixel (%d, %d) is %v, expected %v", px, py, c,
+					expected)
+			}
+
+			r, g, b, a = ColorSpace{}.RGBA(outputs.RawMatrix().Data)
+			i := imgFloat.PixOffset(px, py)
+			for j, v := range []float64{r, g, b, a} {
+				if math.Abs(imgFloat.Pix[i+j]-v) > 1e-9 {
+					t.Fatalf("floating-point pixel (%d, %d) is %v, expected "+
+						"%v", px, py, imgFloat.Pix[i:i+4], []float64{r, g, b, a})
+				}
+			}
+		}
+	}
+}
+
+func TestRenderDepth(t *testing.T) {
+	for _, c := range [][8]float64{
+		{0.5, 0.25, 1.0, 1.0, 127.0, 63.0, 255.0, 255.0},
+		{-0.5, 1.5, 2.0, 1.0, 0.0, 255.0, 255.0, 255.0},
+		{0.5, 0.25, 1.0, 0.5, 127.0, 63.0, 127.0, 127.0},
+		{0.5, 0.0, 0.0, -1.0, 0.0, 0.0, 0.0, 0.0},
+	} {
+		r, g, b, a := quantize(c[0], c[1], c[2], c[3], 255.0)
+		if r != c[4] || g != c[5] || b != c[6] || a != c[7] {
+			t.Errorf("%v is quantized to (%f, %f, %f, %f), expected %v",
+				c[:4], r, g, b, a, c[4:])
+		}
+	}
+
+	rand.Seed(0)
+	g := NewGenome(0, 4, 4, 3)
+	for i := 0; i < 20; i++ {
+		g.Mutate(0.5, 0.5)
+	}
+	r, err := NewRenderer(g, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := NewRenderOptions(16, 16)
+	img8, img16 := r.Render(opts), r.Render64(opts)
+	imgFloat := r.RenderFloat(opts)
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			c8, c16 := img8.RGBAAt(x, y), img16.RGBA64At(x, y)
+			i := imgFloat.PixOffset(x, y)
+			for j, v := range []float64{float64(c8.R), float64(c8.G),
+				float64(c8.B), float64(c8.A)} {
+				f := math.Max(0.0, math.Min(1.0, imgFloat.Pix[i+j]))
+				if v != math.Floor(f*255.0) {
+					t.Fatalf("pixel (%d, %d) is %v, but %v in floating point",
+						x, y, c8, imgFloat.Pix[i:i+4])
+				}
+			}
+			if uint8(c16.R>>8) < c8.R || uint8(c16.R>>8) > c8.R+1 ||
+				c16.A != 0xffff {
+				t.Fatalf("pixel (%d, %d) is %v in 16 bits, but %v in 8 bits",
+					x, y, c16, c8)
+			}
+		}
+	}
+
+	opts.BitDepth = 12
+	if err := opts.Validate(); err == nil {
+		t.Error("bit depth of 12 is valid")
+	}
+}
+
+func TestRenderTorus(t *testing.T) {
+	rand.Seed(0)
+
+	g := NewGenome(0, 6, 4, 3)
+	for i := 0; i < 20; i++ {
+		g.Mutate(0.5, 0.5)
+	}
+	r, err := NewRenderer(g, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// zoomed out to two periods along each axis, each quadrant is the same;
+	// the pan keeps sine and cosine inputs away from zero, where the
+	// discontinuous relu may amplify rounding errors.
+	opts := NewRenderOptions(16, 16)
+	opts.Encoding, opts.Zoom = "torus", 0.5
+	opts.PanX, opts.PanY = 0.3, 0.7
+	if err := opts.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	img := r.Render(opts)
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			c := img.RGBAAt(x, y)
+			for _, p := range [][2]int{{x + 8, y}, {x, y + 8}, {x + 8, y + 8}} {
+				d := img.RGBAAt(p[0], p[1])
+				if absDiff(c.R, d.R) > 1 || absDiff(c.G, d.G) > 1 ||
+					absDiff(c.B, d.B) > 1 {
+					t.Fatalf("pixel (%d, %d) is %v, but (%d, %d) is %v",
+						x, y, c, p[0], p[1], d)
+				}
+			}
+		}
+	}
+
+	opts.Encoding = "polar"
+	if err := opts.Validate(); err == nil {
+		t.Error("unknown input encoding is valid")
+	}
+}
+
+// absDiff returns the absolute difference between two color components.
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
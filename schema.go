@@ -0,0 +1,181 @@
+/*
+
+
+schema.go implementation of the JSON Schema of configurations.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// configPatterns maps string configuration fields to the regular expressions
+// of their valid values.
+var configPatterns = map[string]string{
+	"Symmetry": `^(none|mirror-(x|y|xy)|(rotate|kaleidoscope)-[1-9][0-9]*)?$`,
+}
+
+// hexColorPattern is the regular expression of palette colors.
+const hexColorPattern = `^#[0-9a-fA-F]{6}$`
+
+// configEnums returns the valid values of configuration fields that have a
+// fixed set of them. The empty string and zero select the default.
+func configEnums() map[string][]interface{} {
+	names := func(m interface{}) []interface{} {
+		keys := reflect.ValueOf(m).MapKeys()
+		values := make([]string, len(keys))
+		for i, key := range keys {
+			values[i] = key.String()
+		}
+		sort.Strings(values)
+		enum := []interface{}{""}
+		for _, v := range values {
+			enum = append(enum, v)
+		}
+		return enum
+	}
+	return map[string][]interface{}{
+		"InputEncoding": names(inputEncodings),
+		"ColorSpace":    names(colorSpaces),
+		"RenderFilter":  names(renderFilters),
+		"RenderDepth":   {0, 8, 16},
+	}
+}
+
+// ConfigurationSchema returns the JSON Schema of configuration files, with
+// the type, default and valid values of each field, for editor support.
+func ConfigurationSchema() map[string]interface{} {
+	schema := typeSchema(reflect.TypeOf(Configuration{}))
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = "imagen configuration"
+
+	properties := schema["properties"].(map[string]interface{})
+	defaults := reflect.ValueOf(DefaultConfiguration()).Elem()
+	enums := configEnums()
+	for i := 0; i < defaults.NumField(); i++ {
+		field := defaults.Type().Field(i)
+		p := properties[jsonName(field)].(map[string]interface{})
+		if field.Name == "Schema" {
+			continue
+		}
+		if f := defaults.Field(i); f.Kind() != reflect.Slice {
+			p["default"] = f.Interface()
+		}
+		if r, ok := configRanges[field.Name]; ok {
+			if r.ExclusiveMin {
+				p["exclusiveMinimum"] = r.Min
+			} else {
+				p["minimum"] = r.Min
+			}
+			if !math.IsInf(r.Max, 1) {
+				p["maximum"] = r.Max
+			}
+		}
+		if enum, ok := enums[field.Name]; ok {
+			p["enum"] = enum
+		}
+		if pattern, ok := configPatterns[field.Name]; ok {
+			p["pattern"] = pattern
+		}
+	}
+	palette := properties["Palette"].(map[string]interface{})
+	palette["items"].(map[string]interface{})["pattern"] = hexColorPattern
+	return schema
+}
+
+// typeSchema returns the JSON Schema of values of the argument type, as they
+// are decoded by encoding/json. Structs have no properties but their fields.
+func typeSchema(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array",
+			"items": typeSchema(t.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		for i := 0; i < t.NumField(); i++ {
+			properties[jsonName(t.Field(i))] = typeSchema(t.Field(i).Type)
+		}
+		return map[string]interface{}{"type": "object",
+			"properties": properties, "additionalProperties": false}
+	}
+	panic(fmt.Sprintf("no JSON Schema of type %s", t))
+}
+
+// jsonName returns the name of the argument struct field in JSON.
+func jsonName(field reflect.StructField) string {
+	if name := strings.Split(field.Tag.Get("json"), ",")[0]; name != "" {
+		return name
+	}
+	return field.Name
+}
+
+// marshalSchema returns the indented JSON of the configuration schema.
+func marshalSchema() ([]byte, error) {
+	data, err := json.MarshalIndent(ConfigurationSchema(), "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// schema writes the JSON Schema of configuration files to the argument file,
+// or prints it.
+func schema(args []string) error {
+	if len(args) > 1 {
+		return errors.New("usage: imagen schema [[output].json]")
+	}
+	data, err := marshalSchema()
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(args[0], data, 0644)
+}
@@ -0,0 +1,317 @@
+/*
+
+
+serve.go implementation of interactive evolution served over HTTP.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"image/png"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// galleryTemplate shows a grid of renders of the current population, each of
+// which can be picked as a parent of the next generation.
+var galleryTemplate = template.Must(template.New("gallery").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>Imagen</title>
+<style>
+body { font-family: sans-serif; }
+.grid { display: flex; flex-wrap: wrap; }
+.cell { margin: 4px; text-align: center; }
+.cell img { display: block; border: 1px solid #ccc; }
+</style>
+</head>
+<body>
+<h1>Imagen: generation {{.Generation}}</h1>
+<form method="post" action="/breed">
+<div class="grid">
+{{range .Population}}
+<label class="cell">
+<img src="/render/{{.ID}}.png" width="{{$.Width}}" height="{{$.Height}}">
+<input type="checkbox" name="parent" value="{{.ID}}"> {{.ID}}
+<a href="/genome/{{.ID}}.txt" download>genome</a>
+</label>
+{{end}}
+</div>
+<input type="submit" value="Breed">
+</form>
+</body>
+</html>
+`))
+
+// Gallery is a population of genomes evolved interactively, where the user
+// picks the parents of each generation by eye.
+type Gallery struct {
+	Config     *Configuration // configuration
+	Population []*Genome      // population of genomes
+	Generation int            // current generation
+	Width      int            // width of each render
+	Height     int            // height of each render
+	renders    map[int][]byte // encoded renders by genome ID
+	nextID     int            // ID of the next genome
+	mu         sync.Mutex
+}
+
+// NewGallery creates a new gallery of random genomes, given a configuration
+// and the size of each render.
+func NewGallery(config *Configuration, width, height int) *Gallery {
+	population := make([]*Genome, config.PopulationSize)
+	for i := range population {
+		population[i] = NewGenome(i, config.NumInputs,
+			config.NumInitHidden, config.NumOutputs)
+	}
+
+	return &Gallery{
+		Config:     config,
+		Population: population,
+		Width:      width,
+		Height:     height,
+		renders:    make(map[int][]byte),
+		nextID:     len(population),
+	}
+}
+
+// find returns the genome in the current population with the argument ID, or
+// nil if there is none.
+func (gl *Gallery) find(id int) *Genome {
+	for _, g := range gl.Population {
+		if g.ID == id {
+			return g
+		}
+	}
+	return nil
+}
+
+// Breed replaces the population with the argument parents and their
+// offspring, each created via crossover between two random parents and
+// mutation.
+func (gl *Gallery) Breed(parents []*Genome) {
+	population := make([]*Genome, 0, gl.Config.PopulationSize)
+	population = append(population, parents...)
+	for len(population) < gl.Config.PopulationSize {
+		p1 := parents[rand.Intn(len(parents))]
+		p2 := parents[rand.Intn(len(parents))]
+
+		child := p1.Clone()
+		child.ID = gl.nextID
+		gl.nextID++
+		if p1 != p2 && rand.Float64() < gl.Config.CrossoverRate {
+			child.Crossover(p2)
+			debugValidate(gl.Config, child, "crossover")
+		}
+		child.Mutate(gl.Config.MutAddNodeRate, gl.Config.MutAddEdgeRate)
+		debugValidate(gl.Config, child, "mutation")
+		population = append(population, child)
+	}
+
+	// drop renders of genomes that are no longer in the population
+	renders := make(map[int][]byte)
+	for _, g := range parents {
+		if r, ok := gl.renders[g.ID]; ok {
+			renders[g.ID] = r
+		}
+	}
+
+	gl.renders = renders
+	gl.Population = population
+	gl.Generation++
+}
+
+// ServeHTTP serves the gallery page, renders and genomes of the population,
+// and breeds the next generation from the picked parents.
+func (gl *Gallery) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/":
+		gl.mu.Lock()
+		defer gl.mu.Unlock()
+		if err := galleryTemplate.Execute(w, gl); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+
+	case r.URL.Path == "/breed" && r.Method == http.MethodPost:
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		gl.mu.Lock()
+		defer gl.mu.Unlock()
+
+		// no more parents than the population size are kept
+		parents := make([]*Genome, 0)
+		for _, value := range r.Form["parent"] {
+			id, err := strconv.Atoi(value)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			g := gl.find(id)
+			if g != nil && len(parents) < gl.Config.PopulationSize {
+				parents = append(parents, g)
+			}
+		}
+		if len(parents) == 0 {
+			http.Error(w, "no parents picked", http.StatusBadRequest)
+			return
+		}
+		gl.Breed(parents)
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+
+	case strings.HasPrefix(r.URL.Path, "/render/"):
+		data, err := gl.renderPath(r.URL.Path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if data == nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(data)
+
+	case strings.HasPrefix(r.URL.Path, "/genome/"):
+		g := gl.clonePath(r.URL.Path, "/genome/", ".txt")
+		if g == nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Content-Disposition",
+			fmt.Sprintf("attachment; filename=genome_%d.txt", g.ID))
+		g.ExportTo(w)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// renderPath returns the encoded render of the genome whose ID is in the
+// argument URL path, or nil if there is none. The genome is copied under the
+// lock and rendered outside of it, so that other requests are not blocked.
+func (gl *Gallery) renderPath(path string) ([]byte, error) {
+	gl.mu.Lock()
+	g := gl.findPath(path, "/render/", ".png")
+	if g == nil {
+		gl.mu.Unlock()
+		return nil, nil
+	}
+	if data, ok := gl.renders[g.ID]; ok {
+		gl.mu.Unlock()
+		return data, nil
+	}
+	g = g.Clone()
+	opts := gl.Config.RenderOptions(gl.Width, gl.Height)
+	gl.mu.Unlock()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, render(g, opts)); err != nil {
+		return nil, err
+	}
+
+	// the genome may have been bred out of the population in the meantime
+	gl.mu.Lock()
+	defer gl.mu.Unlock()
+	if gl.find(g.ID) != nil {
+		gl.renders[g.ID] = buf.Bytes()
+	}
+	return buf.Bytes(), nil
+}
+
+// clonePath returns a copy of the genome whose ID is in the argument URL
+// path, or nil if there is none.
+func (gl *Gallery) clonePath(path, prefix, suffix string) *Genome {
+	gl.mu.Lock()
+	defer gl.mu.Unlock()
+	if g := gl.findPath(path, prefix, suffix); g != nil {
+		return g.Clone()
+	}
+	return nil
+}
+
+// findPath returns the genome whose ID is in the argument URL path between
+// the prefix and the suffix, or nil if there is none.
+func (gl *Gallery) findPath(path, prefix, suffix string) *Genome {
+	id, err := strconv.Atoi(strings.TrimSuffix(
+		strings.TrimPrefix(path, prefix), suffix))
+	if err != nil {
+		return nil
+	}
+	return gl.find(id)
+}
+
+// serve starts a local HTTP server for interactive evolution, given a
+// configuration file and an optional address to listen on.
+func serve(args []string) error {
+	if len(args) != 1 && len(args) != 2 {
+		return errors.New("usage: imagen serve [config].json [address]")
+	}
+
+	config, err := NewConfiguration(args[0])
+	if err != nil {
+		return err
+	}
+	if config.PopulationSize < 1 {
+		return errors.New("population size must be at least 1")
+	}
+	if config.ColorSpace == "palette" && len(config.Palette) == 0 {
+		return errors.New("palette outputs need a palette")
+	}
+	if err := config.validateOutputs(); err != nil {
+		return err
+	}
+
+	rand.Seed(config.Seed)
+
+	addr := "localhost:8080"
+	if len(args) == 2 {
+		addr = args[1]
+	}
+
+	width, height := config.Width, config.Height
+	if width <= 0 || height <= 0 {
+		width, height = 128, 128
+	}
+
+	fmt.Printf("Serving interactive evolution at http://%s\n", addr)
+	return http.ListenAndServe(addr, NewGallery(config, width, height))
+}
@@ -0,0 +1,159 @@
+/*
+
+
+serve_test.go tests for the gallery server.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// serveRequest serves the argument request with the argument gallery, and
+// returns the response.
+func serveRequest(gl *Gallery, method, target string,
+	form url.Values) *httptest.ResponseRecorder {
+	var r *http.Request
+	if form != nil {
+		r = httptest.NewRequest(method, target,
+			strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		r = httptest.NewRequest(method, target, nil)
+	}
+	w := httptest.NewRecorder()
+	gl.ServeHTTP(w, r)
+	return w
+}
+
+func TestGallery(t *testing.T) {
+	rand.Seed(0)
+
+	config := DefaultConfiguration()
+	config.PopulationSize = 4
+	gl := NewGallery(config, 8, 8)
+
+	// the page links to the render and the genome of each genome
+	w := serveRequest(gl, http.MethodGet, "/", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("page has status %d", w.Code)
+	}
+	for _, link := range []string{`src="/render/3.png"`,
+		`href="/genome/3.txt"`, `value="3"`, "generation 0"} {
+		if !strings.Contains(w.Body.String(), link) {
+			t.Errorf("page has no %s", link)
+		}
+	}
+
+	// renders are PNG images of the render size, and are cached
+	w = serveRequest(gl, http.MethodGet, "/render/1.png", nil)
+	if w.Code != http.StatusOK ||
+		w.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("render has status %d", w.Code)
+	}
+	img, err := png.Decode(bytes.NewReader(w.Body.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 8 || img.Bounds().Dy() != 8 {
+		t.Errorf("render is %v", img.Bounds())
+	}
+	if !bytes.Equal(gl.renders[1], w.Body.Bytes()) {
+		t.Error("render is not cached")
+	}
+
+	// genomes are exported as text
+	w = serveRequest(gl, http.MethodGet, "/genome/2.txt", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("genome has status %d", w.Code)
+	}
+	var text bytes.Buffer
+	gl.Population[2].ExportTo(&text)
+	if w.Body.String() != text.String() {
+		t.Errorf("genome is\n%s\nexpected\n%s", w.Body.String(), text.String())
+	}
+
+	for _, path := range []string{"/render/99.png", "/genome/x.txt",
+		"/unknown"} {
+		if w := serveRequest(gl, http.MethodGet, path, nil); w.Code !=
+			http.StatusNotFound {
+			t.Errorf("%s has status %d", path, w.Code)
+		}
+	}
+
+	// breeding keeps the parents and their renders
+	w = serveRequest(gl, http.MethodPost, "/breed",
+		url.Values{"parent": {"1", "3"}})
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("breeding has status %d", w.Code)
+	}
+	if gl.Generation != 1 || len(gl.Population) != 4 ||
+		gl.Population[0].ID != 1 || gl.Population[1].ID != 3 {
+		t.Errorf("generation %d has %d genomes", gl.Generation,
+			len(gl.Population))
+	}
+	if _, ok := gl.renders[1]; !ok || len(gl.renders) != 1 {
+		t.Errorf("%d renders are kept", len(gl.renders))
+	}
+
+	// no more parents than the population size are kept
+	w = serveRequest(gl, http.MethodPost, "/breed", url.Values{"parent": {
+		"1", "1", "1", "1", "1", "3"}})
+	if w.Code != http.StatusSeeOther || len(gl.Population) != 4 {
+		t.Errorf("%d genomes are bred", len(gl.Population))
+	}
+
+	// bad forms are rejected
+	for _, form := range []url.Values{{}, {"parent": {"x"}},
+		{"parent": {"99"}}} {
+		if w := serveRequest(gl, http.MethodPost, "/breed", form); w.Code !=
+			http.StatusBadRequest {
+			t.Errorf("breeding from %v has status %d", form, w.Code)
+		}
+	}
+	r := httptest.NewRequest(http.MethodPost, "/breed",
+		strings.NewReader("parent=1&parent=%zz"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	gl.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("malformed form has status %d", w.Code)
+	}
+}
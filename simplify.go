@@ -0,0 +1,452 @@
+/*
+
+
+simplify.go implementation of simplification of genomes.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// edgeKey identifies an edge by the IDs of its input and output nodes.
+type edgeKey struct {
+	From, To int
+}
+
+// simplifier holds a genome being simplified, with at most one edge between
+// any two nodes.
+type simplifier struct {
+	nodes   map[int]*NodeGene   // nodes by ID
+	weights map[edgeKey]float64 // connection weights by edge
+	inputs  map[int][]int       // IDs of input nodes of each node
+	outputs map[int][]int       // IDs of output nodes of each node
+}
+
+// newSimplifier creates a simplifier of a copy of the argument genome.
+// Duplicate edges are merged into the last one, since the last one is what
+// the DPPN uses.
+func newSimplifier(g *Genome) *simplifier {
+	s := &simplifier{
+		nodes:   make(map[int]*NodeGene),
+		weights: make(map[edgeKey]float64),
+	}
+	for _, node := range g.NodeGenes {
+		s.nodes[node.ID] = NewNodeGene(node.ID, node.Type, node.AFuncType)
+	}
+	for _, edge := range g.EdgeGenes {
+		s.weights[edgeKey{edge.InputNode.ID, edge.OutputNode.ID}] = edge.Weight
+	}
+	s.index()
+	return s
+}
+
+// index rebuilds the input and output lists of each node.
+func (s *simplifier) index() {
+	s.inputs = make(map[int][]int)
+	s.outputs = make(map[int][]int)
+	for _, key := range s.keys() {
+		s.inputs[key.To] = append(s.inputs[key.To], key.From)
+		s.outputs[key.From] = append(s.outputs[key.From], key.To)
+	}
+}
+
+// keys returns the edges in order of their input and output node IDs.
+func (s *simplifier) keys() []edgeKey {
+	keys := make([]edgeKey, 0, len(s.weights))
+	for key := range s.weights {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].From != keys[j].From {
+			return keys[i].From < keys[j].From
+		}
+		return keys[i].To < keys[j].To
+	})
+	return keys
+}
+
+// reachable returns the set of IDs of nodes reachable from nodes of the
+// argument type, following edges forward, or backward if reverse is true.
+func (s *simplifier) reachable(nodeType string, reverse bool) map[int]bool {
+	visited := make(map[int]bool)
+	stack := make([]int, 0)
+	for id, node := range s.nodes {
+		if node.Type == nodeType {
+			visited[id] = true
+			stack = append(stack, id)
+		}
+	}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		next := s.outputs[id]
+		if reverse {
+			next = s.inputs[id]
+		}
+		for _, n := range next {
+			if !visited[n] {
+				visited[n] = true
+				stack = append(stack, n)
+			}
+		}
+	}
+	return visited
+}
+
+// constant returns the signal of a node that is not reachable from any
+// input node, and therefore has the same signal for every input. As in the
+// DPPN, a node without inputs has a signal of zero.
+func (s *simplifier) constant(id int, memo map[int]float64) float64 {
+	if c, ok := memo[id]; ok {
+		return c
+	}
+	memo[id] = 0.0 // guards against cycles
+	if len(s.inputs[id]) == 0 {
+		return 0.0
+	}
+
+	sum := 0.0
+	for _, in := range s.inputs[id] {
+		sum += s.weights[edgeKey{in, id}] * s.constant(in, memo)
+	}
+	c := aFuncSet[s.nodes[id].AFuncType].Fn(sum)
+	memo[id] = c
+	return c
+}
+
+// removeNode removes the argument node and all of its edges.
+func (s *simplifier) removeNode(id int) {
+	for _, in := range s.inputs[id] {
+		delete(s.weights, edgeKey{in, id})
+	}
+	for _, out := range s.outputs[id] {
+		delete(s.weights, edgeKey{id, out})
+	}
+	delete(s.nodes, id)
+}
+
+// foldWeights removes edges whose contribution to their output node is
+// within the argument tolerance of zero: edges with near-zero weights, and
+// edges from constant nodes with near-zero signals. An edge is never removed
+// if it is the last input of its output node, since a node without inputs
+// has a signal of zero instead of the activation of zero. It returns true if
+// any edge was removed.
+func (s *simplifier) foldWeights(tol float64) bool {
+	variable := s.reachable("input", false)
+	memo := make(map[int]float64)
+	fanIn := make(map[int]int)
+	for id, inputs := range s.inputs {
+		fanIn[id] = len(inputs)
+	}
+
+	changed := false
+	for _, key := range s.keys() {
+		w := s.weights[key]
+		contrib := w
+		if !variable[key.From] {
+			contrib = w * s.constant(key.From, memo)
+		}
+		if math.Abs(w) <= tol || (variable[key.To] &&
+			!variable[key.From] && math.Abs(contrib) <= tol) {
+			if fanIn[key.To] > 1 {
+				delete(s.weights, key)
+				fanIn[key.To]--
+				changed = true
+			}
+		}
+	}
+	if changed {
+		s.index()
+	}
+	return changed
+}
+
+// mergeIdentity merges a hidden identity node with a single input or a
+// single output into its neighbors: each path through the node is replaced
+// by a direct edge weighted by the product of the path's weights, which
+// computes the same sum, since the node is linear. It returns true if a node
+// was merged.
+func (s *simplifier) mergeIdentity() bool {
+	ids := make([]int, 0, len(s.nodes))
+	for id := range s.nodes {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		node := s.nodes[id]
+		inputs, outputs := s.inputs[id], s.outputs[id]
+		if node.Type != "hidden" || node.AFuncType != "identity" ||
+			len(inputs) == 0 || len(outputs) == 0 ||
+			(len(inputs) > 1 && len(outputs) > 1) {
+			continue
+		}
+
+		for _, in := range inputs {
+			for _, out := range outputs {
+				key := edgeKey{in, out}
+				s.weights[key] += s.weights[edgeKey{in, id}] *
+					s.weights[edgeKey{id, out}]
+			}
+		}
+		s.removeNode(id)
+		s.index()
+		return true
+	}
+	return false
+}
+
+// removeDead removes hidden nodes that cannot reach any output node, and
+// hidden nodes that no input node reaches and whose signal of zero adds
+// nothing to the nodes they feed, unless a node they feed would be left
+// without inputs. It returns true if any node was removed.
+func (s *simplifier) removeDead() bool {
+	toOutputs := s.reachable("output", true)
+	fromInputs := s.reachable("input", false)
+	memo := make(map[int]float64)
+	fanIn := make(map[int]int)
+	for id, inputs := range s.inputs {
+		fanIn[id] = len(inputs)
+	}
+
+	ids := make([]int, 0, len(s.nodes))
+	for id := range s.nodes {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	changed := false
+	for _, id := range ids {
+		if s.nodes[id].Type != "hidden" {
+			continue
+		}
+		if !toOutputs[id] {
+			s.removeNode(id)
+			changed = true
+			continue
+		}
+		if fromInputs[id] || s.constant(id, memo) != 0.0 {
+			continue
+		}
+		removable := true
+		for _, out := range s.outputs[id] {
+			w := s.weights[edgeKey{id, out}]
+			if math.IsNaN(w) || math.IsInf(w, 0) || fanIn[out] < 2 {
+				removable = false
+			}
+		}
+		if removable {
+			for _, out := range s.outputs[id] {
+				fanIn[out]--
+			}
+			s.removeNode(id)
+			changed = true
+		}
+	}
+	if changed {
+		s.index()
+	}
+	return changed
+}
+
+// foldNaN replaces the inputs of each node whose input sum is always NaN,
+// because an input edge has a NaN weight or comes from a node whose signal is
+// always NaN, by a single NaN-weighted edge from the first input node, which
+// keeps the sum NaN. Diverged training leaves such weights, and the nodes
+// that fed the node may then be dead. It returns true if any edge changed.
+func (s *simplifier) foldNaN() bool {
+	ids := make([]int, 0, len(s.nodes))
+	input := -1
+	for id, node := range s.nodes {
+		ids = append(ids, id)
+		if node.Type == "input" && (input == -1 || id < input) {
+			input = id
+		}
+	}
+	sort.Ints(ids)
+	if input == -1 {
+		return false
+	}
+
+	// propagate NaN sums and signals until they no longer change
+	sumNaN, signalNaN := make(map[int]bool), make(map[int]bool)
+	for changed := true; changed; {
+		changed = false
+		for _, id := range ids {
+			if sumNaN[id] {
+				continue
+			}
+			for _, in := range s.inputs[id] {
+				if math.IsNaN(s.weights[edgeKey{in, id}]) || signalNaN[in] {
+					sumNaN[id] = true
+					signalNaN[id] = math.IsNaN(
+						aFuncSet[s.nodes[id].AFuncType].Fn(math.NaN()))
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	changed := false
+	for _, id := range ids {
+		inputs := s.inputs[id]
+		if !sumNaN[id] || (len(inputs) == 1 && inputs[0] == input &&
+			math.IsNaN(s.weights[edgeKey{input, id}])) {
+			continue
+		}
+		for _, in := range inputs {
+			delete(s.weights, edgeKey{in, id})
+		}
+		s.weights[edgeKey{input, id}] = math.NaN()
+		changed = true
+	}
+	if changed {
+		s.index()
+	}
+	return changed
+}
+
+// genome returns the simplified genome with the argument ID, with its node
+// IDs renumbered compactly: input nodes first, then output nodes, then
+// hidden nodes, each in their original order.
+func (s *simplifier) genome(id int) *Genome {
+	old := make([]*NodeGene, 0, len(s.nodes))
+	for _, node := range s.nodes {
+		old = append(old, node)
+	}
+	order := map[string]int{"input": 0, "output": 1, "hidden": 2}
+	sort.Slice(old, func(i, j int) bool {
+		if order[old[i].Type] != order[old[j].Type] {
+			return order[old[i].Type] < order[old[j].Type]
+		}
+		return old[i].ID < old[j].ID
+	})
+
+	g := &Genome{
+		ID:        id,
+		NodeGenes: make([]*NodeGene, len(old)),
+		EdgeGenes: make([]*EdgeGene, 0, len(s.weights)),
+	}
+	renumbered := make(map[int]*NodeGene)
+	for i, node := range old {
+		g.NodeGenes[i] = NewNodeGene(i, node.Type, node.AFuncType)
+		renumbered[node.ID] = g.NodeGenes[i]
+		switch node.Type {
+		case "input":
+			g.NumInputs++
+		case "output":
+			g.NumOutputs++
+		default:
+			g.NumHidden++
+		}
+	}
+	for _, key := range s.keys() {
+		g.EdgeGenes = append(g.EdgeGenes, &EdgeGene{
+			InputNode:  renumbered[key.From],
+			OutputNode: renumbered[key.To],
+			Weight:     s.weights[key],
+		})
+	}
+	sort.Slice(g.EdgeGenes, func(i, j int) bool {
+		ei, ej := g.EdgeGenes[i], g.EdgeGenes[j]
+		if ei.InputNode.ID != ej.InputNode.ID {
+			return ei.InputNode.ID < ej.InputNode.ID
+		}
+		return ei.OutputNode.ID < ej.OutputNode.ID
+	})
+
+	return g
+}
+
+// Simplify returns a simplified copy of the genome that computes the same
+// outputs, within the effect of removing weights no larger than the argument
+// tolerance. It removes hidden nodes that cannot reach an output, hidden
+// nodes that no input reaches and that have a signal of zero, edges that
+// contribute nothing, and all but one input of nodes whose input sum is
+// always NaN. It merges chains of identity nodes, and renumbers the node IDs
+// compactly. A tolerance of 0.0 removes only what does not change the outputs
+// at all; with a larger tolerance, outputs may jump where a node's input sum
+// is within the removed amount of zero, since relu is discontinuous there.
+func (g *Genome) Simplify(tol float64) *Genome {
+	s := newSimplifier(g)
+	for {
+		changed := s.foldNaN()
+		changed = s.foldWeights(tol) || changed
+		changed = s.mergeIdentity() || changed
+		changed = s.removeDead() || changed
+		if !changed {
+			break
+		}
+	}
+
+	simplified := s.genome(g.ID)
+	simplified.Fitness = g.Fitness
+	return simplified
+}
+
+// simplify simplifies an exported genome, and writes the result with the
+// genome's metadata in the encoding given by the output file's extension, as
+// in WriteGenome.
+func simplify(args []string) error {
+	if len(args) != 2 && len(args) != 3 {
+		return errors.New("usage: imagen simplify [genome] " +
+			"[output](.json|.bin|.txt) [tolerance]")
+	}
+
+	tol := 0.0
+	if len(args) == 3 {
+		var err error
+		if tol, err = strconv.ParseFloat(args[2], 64); err != nil {
+			return err
+		}
+	}
+
+	g, meta, err := OpenGenome(args[0])
+	if err != nil {
+		return err
+	}
+	simplified := g.Simplify(tol)
+	if err := WriteGenome(args[1], simplified, meta); err != nil {
+		return err
+	}
+
+	fmt.Printf("Nodes: %d -> %d\n", len(g.NodeGenes), len(simplified.NodeGenes))
+	fmt.Printf("Edges: %d -> %d\n", len(g.EdgeGenes), len(simplified.EdgeGenes))
+	return nil
+}
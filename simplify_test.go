@@ -0,0 +1,165 @@
+package main
+
+import (
+	"github.com/gonum/matrix/mat64"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"testing"
+)
+
+// maxOutputDiff returns the largest difference between the outputs of the
+// DPPNs of two genomes, given a batch of random inputs. Outputs that are both
+// NaN are equal, and an output that is NaN in only one differs infinitely.
+func maxOutputDiff(t *testing.T, g0, g1 *Genome, batchSize int) float64 {
+	inputs := make([]float64, batchSize*g0.NumInputs)
+	for i := range inputs {
+		inputs[i] = rand.Float64()*20.0 - 10.0
+	}
+	inputBatch := mat64.NewDense(batchSize, g0.NumInputs, inputs)
+
+	n0, err := NewDPPN(g0, batchSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n1, err := NewDPPN(g1, batchSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out0, err := n0.FeedForward(inputBatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out1, err := n1.FeedForward(inputBatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diff := 0.0
+	for i, v := range out0.RawMatrix().Data {
+		v1 := out1.RawMatrix().Data[i]
+		if math.IsNaN(v) != math.IsNaN(v1) {
+			return math.Inf(1)
+		} else if !math.IsNaN(v) {
+			diff = math.Max(diff, math.Abs(v-v1))
+		}
+	}
+	return diff
+}
+
+func TestSimplify(t *testing.T) {
+	rand.Seed(0)
+
+	for i := 0; i < 20; i++ {
+		g0 := NewGenome(0, 4, 4, 3)
+		g1 := NewGenome(1, 4, 4, 3)
+		for j := 0; j < 20; j++ {
+			g0.Mutate(0.5, 0.5)
+			g1.Mutate(0.5, 0.5)
+		}
+		g0.Crossover(g1)
+
+		// add some zero weights to be folded
+		for _, edge := range g0.EdgeGenes {
+			if rand.Float64() < 0.1 {
+				edge.Weight = 0.0
+			}
+		}
+
+		simplified := g0.Simplify(0.0)
+		if len(simplified.NodeGenes) > len(g0.NodeGenes) ||
+			len(simplified.EdgeGenes) > len(g0.EdgeGenes) {
+			t.Errorf("simplified genome is larger: %d/%d nodes, %d/%d edges",
+				len(simplified.NodeGenes), len(g0.NodeGenes),
+				len(simplified.EdgeGenes), len(g0.EdgeGenes))
+		}
+		for j, node := range simplified.NodeGenes {
+			if node.ID != j {
+				t.Fatalf("node %d has ID %d after renumbering", j, node.ID)
+			}
+		}
+		if diff := maxOutputDiff(t, g0, simplified, 16); diff > 1e-6 {
+			t.Errorf("outputs differ by %g after simplification", diff)
+		}
+	}
+}
+
+func TestSimplifyExported(t *testing.T) {
+	rand.Seed(0)
+
+	files, err := filepath.Glob("tests/butterfly/genome_*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, filename := range files {
+		g, err := LoadGenome(filename, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		simplified := g.Simplify(0.0)
+		if diff := maxOutputDiff(t, g, simplified, 16); diff > 1e-6 {
+			t.Errorf("%s: outputs differ by %g after simplification",
+				filename, diff)
+		}
+	}
+}
+
+func TestSimplifyNaN(t *testing.T) {
+	rand.Seed(0)
+
+	// the trained weights of this genome diverged, and it has duplicate edges
+	filenames, err := filepath.Glob(filepath.Join("tests", "mnist-5",
+		"genome_30_*.txt"))
+	if err != nil || len(filenames) != 1 {
+		t.Fatalf("genome not found: %v", err)
+	}
+	g, err := LoadGenome(filenames[0], 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	simplified := g.Simplify(0.0)
+	if diff := maxOutputDiff(t, g, simplified, 16); diff > 1e-6 {
+		t.Errorf("outputs differ by %g after simplification", diff)
+	}
+	// every weight is NaN, so each output needs only an edge from an input
+	if n := len(simplified.NodeGenes); n > g.NumInputs+g.NumOutputs {
+		t.Errorf("simplified genome has %d of %d nodes", n, len(g.NodeGenes))
+	}
+	if n := len(simplified.EdgeGenes); n > g.NumOutputs {
+		t.Errorf("simplified genome has %d of %d edges", n, len(g.EdgeGenes))
+	}
+}
+
+func TestSimplifyCommand(t *testing.T) {
+	rand.Seed(0)
+
+	g := NewGenome(0, 4, 4, 3)
+	for i := 0; i < 20; i++ {
+		g.Mutate(0.5, 0.5)
+	}
+	config := DefaultConfiguration()
+	config.Width, config.Height = 60, 60
+	dir := t.TempDir()
+	input := filepath.Join(dir, "genome.json")
+	if err := WriteGenome(input, g, NewGenomeMeta(g, config)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the metadata and the weights of the simplified genome are kept exactly
+	output := filepath.Join(dir, "simplified.json")
+	if err := simplify([]string{input, output}); err != nil {
+		t.Fatal(err)
+	}
+	simplified, meta, err := OpenGenome(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Config == nil || meta.Config.Width != 60 ||
+		meta.Config.Height != 60 {
+		t.Errorf("metadata of the simplified genome is %+v", meta)
+	}
+	if simplified.Hash() != g.Simplify(0.0).Hash() {
+		t.Error("simplified genome is not written at full precision")
+	}
+}
@@ -0,0 +1,416 @@
+/*
+
+
+store.go implementation of a content-addressed genome store.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound is returned by a store when it has no genome with the
+// requested ID.
+var ErrNotFound = errors.New("genome not found")
+
+// GenomeRecord is a genome in a store along with its lineage. The genome is
+// addressed by its canonical hash (Genome.Hash).
+type GenomeRecord struct {
+	ID        string          // content address of the genome
+	Parents   []string        // IDs of the genomes it was created from
+	Mutations []string        // operations applied to the parents
+	Fitness   float64         // fitness score, if evaluated
+	Evaluated bool            // whether the fitness score is known
+	RunID     string          // ID of the run the genome came from
+	Genome    json.RawMessage // genome in its JSON encoding
+}
+
+// NewGenomeRecord creates a new record of the argument genome, given its
+// parents, the operations applied to them, and the ID of the run.
+func NewGenomeRecord(g *Genome, parents, mutations []string,
+	runID string) *GenomeRecord {
+	// encoding into memory only fails for unsupported values, which
+	// genomes do not have
+	var data bytes.Buffer
+	EncodeGenomeJSON(&data, g, NewGenomeMeta(g, nil))
+	return &GenomeRecord{
+		ID:        g.Hash(),
+		Parents:   parents,
+		Mutations: mutations,
+		Fitness:   g.Fitness,
+		RunID:     runID,
+		Genome:    data.Bytes(),
+	}
+}
+
+// Decode decodes the record's genome, and assigns it the argument ID.
+func (r *GenomeRecord) Decode(id int) (*Genome, error) {
+	g, _, err := DecodeGenomeJSON(bytes.NewReader(r.Genome))
+	if err != nil {
+		return nil, err
+	}
+	g.ID = id
+	g.Fitness = r.Fitness
+	return g, nil
+}
+
+// Store defines a type of storage of genome records, addressed by the
+// content of their genomes. Putting a genome that is already stored only
+// updates its fitness score, if the new record is evaluated.
+type Store interface {
+	Put(r *GenomeRecord) error
+	Get(id string) (*GenomeRecord, error)
+	List() ([]string, error)
+	Close() error
+}
+
+// update sets the fitness score of the stored record to that of the argument
+// record, if it is evaluated, and returns whether the stored record changed.
+func (r *GenomeRecord) update(evaluated *GenomeRecord) bool {
+	if !evaluated.Evaluated ||
+		(r.Evaluated && r.Fitness == evaluated.Fitness) {
+		return false
+	}
+	r.Fitness, r.Evaluated = evaluated.Fitness, true
+	return true
+}
+
+// OpenStore opens the store at the argument path: an embedded key-value
+// store if the path ends with ".db", a directory of records otherwise.
+func OpenStore(path string) (Store, error) {
+	if strings.HasSuffix(path, ".db") {
+		return OpenLogStore(path)
+	}
+	return OpenDirStore(path)
+}
+
+// DirStore is a store that keeps each record in its own JSON file in a
+// directory.
+type DirStore struct {
+	Dir string // directory of records
+}
+
+// OpenDirStore opens the store in the argument directory, creating the
+// directory if it does not exist.
+func OpenDirStore(dir string) (*DirStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &DirStore{Dir: dir}, nil
+}
+
+func (s *DirStore) path(id string) string {
+	return filepath.Join(s.Dir, id+".json")
+}
+
+// Put writes the argument record to its own file, or updates the fitness
+// score of the stored record.
+func (s *DirStore) Put(r *GenomeRecord) error {
+	if _, err := os.Stat(s.path(r.ID)); err == nil {
+		stored, err := s.Get(r.ID)
+		if err != nil || !stored.update(r) {
+			return err
+		}
+		r = stored
+	}
+	data, err := json.MarshalIndent(r, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first, so that a record is never partially
+	// written.
+	tmp := s.path(r.ID) + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path(r.ID))
+}
+
+// Get reads the record with the argument ID.
+func (s *DirStore) Get(id string) (*GenomeRecord, error) {
+	data, err := ioutil.ReadFile(s.path(id))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	var r GenomeRecord
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// List returns the IDs of all the records in the directory.
+func (s *DirStore) List() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(s.Dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(files))
+	for i, file := range files {
+		ids[i] = strings.TrimSuffix(filepath.Base(file), ".json")
+	}
+	return ids, nil
+}
+
+// Close does nothing, since each record is written as it is put.
+func (s *DirStore) Close() error {
+	return nil
+}
+
+// LogStore is an embedded key-value store that appends each record to a
+// single log file as a line of JSON, and keeps an index of the records in
+// memory.
+type LogStore struct {
+	Path    string                   // path of the log file
+	records map[string]*GenomeRecord // records by ID
+	f       *os.File
+	mu      sync.Mutex
+}
+
+// OpenLogStore opens the store in the argument log file, creating the file
+// if it does not exist.
+func OpenLogStore(path string) (*LogStore, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &LogStore{
+		Path:    path,
+		records: make(map[string]*GenomeRecord),
+		f:       f,
+	}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var r GenomeRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s:%d: %s", path, line, err)
+		}
+		// later lines of a record update its fitness score
+		if stored, ok := s.records[r.ID]; ok {
+			stored.update(&r)
+		} else {
+			s.records[r.ID] = &r
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Put appends the argument record to the log, or appends the stored record
+// with its fitness score updated.
+func (s *LogStore) Put(r *GenomeRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stored, ok := s.records[r.ID]; ok {
+		if !stored.update(r) {
+			return nil
+		}
+		r = stored
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := s.f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	s.records[r.ID] = r
+	return nil
+}
+
+// Get returns the record with the argument ID.
+func (s *LogStore) Get(id string) (*GenomeRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.records[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return r, nil
+}
+
+// List returns the IDs of all the records in the log.
+func (s *LogStore) List() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]string, 0, len(s.records))
+	for id := range s.records {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Close closes the log file.
+func (s *LogStore) Close() error {
+	return s.f.Close()
+}
+
+// Ancestors returns the records of all the ancestors of the genome with the
+// argument ID in the store, nearest first. Ancestors that are not in the
+// store are skipped.
+func Ancestors(s Store, id string) ([]*GenomeRecord, error) {
+	r, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	ancestors := make([]*GenomeRecord, 0)
+	visited := map[string]bool{id: true}
+	queue := r.Parents
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+		if visited[pid] {
+			continue
+		}
+		visited[pid] = true
+
+		p, err := s.Get(pid)
+		if err == ErrNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		ancestors = append(ancestors, p)
+		queue = append(queue, p.Parents...)
+	}
+
+	return ancestors, nil
+}
+
+// Descendants returns the records of all the descendants of the genome with
+// the argument ID in the store, nearest first.
+func Descendants(s Store, id string) ([]*GenomeRecord, error) {
+	if _, err := s.Get(id); err != nil {
+		return nil, err
+	}
+	ids, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	// index the children of each genome
+	children := make(map[string][]*GenomeRecord)
+	for _, cid := range ids {
+		c, err := s.Get(cid)
+		if err != nil {
+			return nil, err
+		}
+		for _, pid := range c.Parents {
+			children[pid] = append(children[pid], c)
+		}
+	}
+
+	descendants := make([]*GenomeRecord, 0)
+	visited := map[string]bool{id: true}
+	queue := []string{id}
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+		for _, c := range children[pid] {
+			if visited[c.ID] {
+				continue
+			}
+			visited[c.ID] = true
+			descendants = append(descendants, c)
+			queue = append(queue, c.ID)
+		}
+	}
+
+	return descendants, nil
+}
+
+// printLineage runs a lineage query on a store, given the store path and a
+// genome ID, and prints the resulting records.
+func printLineage(args []string, name string,
+	query func(Store, string) ([]*GenomeRecord, error)) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: imagen %s [store] [id]", name)
+	}
+
+	s, err := OpenStore(args[0])
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	records, err := query(s, args[1])
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		fitness := "-"
+		if r.Evaluated {
+			fitness = fmt.Sprintf("%f", r.Fitness)
+		}
+		fmt.Printf("%s %s %s [%s]\n", r.ID, r.RunID, fitness,
+			strings.Join(r.Mutations, ", "))
+	}
+	return nil
+}
+
+// ancestors lists the ancestors of a genome in a store.
+func ancestors(args []string) error {
+	return printLineage(args, "ancestors", Ancestors)
+}
+
+// descendants lists the descendants of a genome in a store.
+func descendants(args []string) error {
+	return printLineage(args, "descendants", Descendants)
+}
@@ -0,0 +1,209 @@
+/*
+
+
+store_test.go tests for genome stores and lineage queries.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// lineage puts a lineage of genomes in the argument store: a root, two
+// children of the root, and a grandchild of both children. It returns the
+// IDs of the root, the children and the grandchild.
+func lineage(t *testing.T, s Store) []string {
+	root := NewGenome(0, 4, 2, 3)
+	genomes := []*Genome{root, root.Clone(), root.Clone(), nil}
+	genomes[1].AddNode()
+	genomes[2].AddEdge()
+	genomes[2].EdgeGenes[0].Weight += 1.0
+	genomes[3] = genomes[1].Clone()
+	genomes[3].Crossover(genomes[2])
+	genomes[3].EdgeGenes[0].Weight += 1.0
+
+	ids := make([]string, len(genomes))
+	for i, g := range genomes {
+		ids[i] = g.Hash()
+	}
+	parents := [][]string{nil, {ids[0]}, {ids[0]}, {ids[1], ids[2]}}
+	for i, g := range genomes {
+		g.Fitness = float64(i)
+		if err := s.Put(NewGenomeRecord(g, parents[i], nil, "run")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// putting a genome again has no effect
+	if err := s.Put(NewGenomeRecord(root, []string{ids[3]}, nil,
+		"run")); err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+// recordIDs returns the IDs of the argument records, sorted.
+func recordIDs(records []*GenomeRecord) []string {
+	ids := make([]string, len(records))
+	for i, r := range records {
+		ids[i] = r.ID
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// sameIDs returns true if the argument ID lists have the same IDs.
+func sameIDs(a, b []string) bool {
+	a, b = append([]string{}, a...), append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestStores(t *testing.T) {
+	rand.Seed(0)
+
+	for _, name := range []string{"records", "records.db"} {
+		path := filepath.Join(t.TempDir(), name)
+		s, err := OpenStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := lineage(t, s)
+
+		list, err := s.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sameIDs(list, ids) {
+			t.Errorf("%s: store has %v, expected %v", name, list, ids)
+		}
+
+		// records decode to the genomes they address, with exact weights
+		for i, id := range ids {
+			r, err := s.Get(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			g, err := r.Decode(7)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if g.Hash() != id || g.ID != 7 || g.Fitness != float64(i) {
+				t.Errorf("%s: record %s decodes to genome %s", name, id,
+					g.Hash())
+			}
+		}
+		if _, err := s.Get("unknown"); err != ErrNotFound {
+			t.Errorf("%s: unknown ID has error %v", name, err)
+		}
+
+		// lineage queries
+		for _, c := range []struct {
+			query    func(Store, string) ([]*GenomeRecord, error)
+			id       string
+			expected []string
+		}{
+			{Ancestors, ids[3], ids[:3]},
+			{Ancestors, ids[1], ids[:1]},
+			{Ancestors, ids[0], nil},
+			{Descendants, ids[0], ids[1:]},
+			{Descendants, ids[2], ids[3:]},
+			{Descendants, ids[3], nil},
+		} {
+			records, err := c.query(s, c.id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !sameIDs(recordIDs(records), c.expected) {
+				t.Errorf("%s: lineage of %s is %v, expected %v", name, c.id,
+					recordIDs(records), c.expected)
+			}
+		}
+		// ancestors are listed nearest first
+		records, err := Ancestors(s, ids[3])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if records[len(records)-1].ID != ids[0] {
+			t.Errorf("%s: farthest ancestor is not the root", name)
+		}
+
+		// putting an evaluated genome again updates its fitness score only
+		root, _ := s.Get(ids[0])
+		g, _ := root.Decode(0)
+		g.Fitness = 9.0
+		evaluated := NewGenomeRecord(g, nil, nil, "run")
+		evaluated.Evaluated = true
+		if err := s.Put(evaluated); err != nil {
+			t.Fatal(err)
+		}
+
+		// a reopened store has the same records
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+		s, err = OpenStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		list, err = s.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sameIDs(list, ids) {
+			t.Errorf("%s: reopened store has %v, expected %v", name, list, ids)
+		}
+		if r, err := s.Get(ids[0]); err != nil {
+			t.Fatal(err)
+		} else if !r.Evaluated || r.Fitness != 9.0 || len(r.Parents) != 0 {
+			t.Errorf("%s: updated record is %+v", name, r)
+		}
+		if r, err := s.Get(ids[1]); err != nil {
+			t.Fatal(err)
+		} else if r.Evaluated || r.Fitness != 1.0 {
+			t.Errorf("%s: record %s is updated", name, ids[1])
+		}
+		s.Close()
+	}
+}
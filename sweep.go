@@ -0,0 +1,482 @@
+/*
+
+
+sweep.go implementation of hyperparameter sweeps.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// SweepParameter is the values of a swept configuration field: a list of
+// values, or a range of numbers.
+type SweepParameter struct {
+	Values []interface{} // values of the field, if any, instead of a range
+	Min    float64       // minimum of the range
+	Max    float64       // maximum of the range
+	Steps  int           // number of evenly spaced values in grid search
+	Log    bool          // space or sample values logarithmically
+}
+
+// Sweep is a hyperparameter sweep: training runs of configurations that
+// vary the fields of a base configuration, each with several seeds.
+type Sweep struct {
+	Base       string                    // base configuration file
+	Method     string                    // grid (default) or random search
+	NumSamples int                       // configurations in random search
+	NumSeeds   int                       // seeds of each configuration, or 1 if zero
+	NumWorkers int                       // concurrent runs, or one per CPU if zero
+	Directory  string                    // directory of the runs, or sweep if empty
+	Parameters map[string]SweepParameter // swept configuration fields
+}
+
+// NewSweep reads a sweep from the argument JSON file, and validates it.
+func NewSweep(filename string) (*Sweep, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+
+	var s Sweep
+	if err := dec.Decode(&s); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	if s.Method == "" {
+		s.Method = "grid"
+	}
+	if s.NumSeeds == 0 {
+		s.NumSeeds = 1
+	}
+	if s.NumWorkers == 0 {
+		s.NumWorkers = runtime.NumCPU()
+	}
+	if s.Directory == "" {
+		s.Directory = "sweep"
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Validate returns an error if the sweep has no base configuration, an
+// unknown method, or if a parameter is not a field of configurations or has
+// no values.
+func (s *Sweep) Validate() error {
+	if s.Base == "" {
+		return errors.New("sweep has no base configuration")
+	}
+	switch s.Method {
+	case "grid":
+	case "random":
+		if s.NumSamples < 1 {
+			return errors.New("random sweep has no samples (NumSamples)")
+		}
+	default:
+		return fmt.Errorf("unknown sweep method %s", s.Method)
+	}
+	if s.NumSeeds < 1 || s.NumWorkers < 1 {
+		return errors.New("sweep has no seeds or workers")
+	}
+
+	for _, name := range s.names() {
+		kind := sweepKind(name)
+		if kind == reflect.Invalid {
+			return fmt.Errorf("%s cannot be swept", name)
+		}
+		p := s.Parameters[name]
+		if len(p.Values) > 0 {
+			continue
+		}
+		switch {
+		case kind != reflect.Int && kind != reflect.Float64:
+			return fmt.Errorf("%s has no values", name)
+		case p.Min > p.Max:
+			return fmt.Errorf("%s has an empty range", name)
+		case p.Log && p.Min <= 0.0:
+			return fmt.Errorf("%s has a logarithmic range that is not "+
+				"positive", name)
+		case s.Method == "grid" && p.Steps < 1:
+			return fmt.Errorf("%s has a range without steps", name)
+		}
+	}
+	return nil
+}
+
+// sweepKind returns the kind of the argument configuration field, or
+// reflect.Invalid if it cannot be swept. The seed is swept separately.
+func sweepKind(name string) reflect.Kind {
+	field, ok := reflect.TypeOf(Configuration{}).FieldByName(name)
+	if !ok || name == "Seed" || name == "Schema" {
+		return reflect.Invalid
+	}
+	switch kind := field.Type.Kind(); kind {
+	case reflect.Int, reflect.Float64, reflect.String, reflect.Bool:
+		return kind
+	case reflect.Int64:
+		return reflect.Int
+	}
+	return reflect.Invalid
+}
+
+// names returns the names of the swept fields, sorted.
+func (s *Sweep) names() []string {
+	names := make([]string, 0, len(s.Parameters))
+	for name := range s.Parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// grid returns the values of the parameter of the argument field in grid
+// search: its list of values, or evenly spaced values over its range.
+func (p SweepParameter) grid(name string) []interface{} {
+	if len(p.Values) > 0 {
+		return p.Values
+	}
+	values := make([]interface{}, p.Steps)
+	for i := range values {
+		t := 0.0
+		if p.Steps > 1 {
+			t = float64(i) / float64(p.Steps-1)
+		}
+		values[i] = p.at(name, t)
+	}
+	return values
+}
+
+// sample returns a random value of the parameter of the argument field: one
+// of its values, or a uniformly distributed value in its range.
+func (p SweepParameter) sample(name string, rng *rand.Rand) interface{} {
+	if len(p.Values) > 0 {
+		return p.Values[rng.Intn(len(p.Values))]
+	}
+	return p.at(name, rng.Float64())
+}
+
+// at returns the value at the argument fraction of the range, rounded if the
+// field is an integer.
+func (p SweepParameter) at(name string, t float64) interface{} {
+	v := p.Min + t*(p.Max-p.Min)
+	if p.Log {
+		v = math.Exp(math.Log(p.Min) + t*(math.Log(p.Max)-math.Log(p.Min)))
+	}
+	if sweepKind(name) == reflect.Int {
+		return int(math.Round(v))
+	}
+	return v
+}
+
+// Configurations returns the values of the swept fields of each
+// configuration: every combination of their values in grid search, or
+// NumSamples random combinations in random search.
+func (s *Sweep) Configurations(rng *rand.Rand) []map[string]interface{} {
+	names := s.names()
+	if s.Method == "random" {
+		configs := make([]map[string]interface{}, s.NumSamples)
+		for i := range configs {
+			configs[i] = make(map[string]interface{})
+			for _, name := range names {
+				configs[i][name] = s.Parameters[name].sample(name, rng)
+			}
+		}
+		return configs
+	}
+
+	configs := []map[string]interface{}{{}}
+	for _, name := range names {
+		next := make([]map[string]interface{}, 0)
+		for _, config := range configs {
+			for _, v := range s.Parameters[name].grid(name) {
+				c := map[string]interface{}{name: v}
+				for k, u := range config {
+					c[k] = u
+				}
+				next = append(next, c)
+			}
+		}
+		configs = next
+	}
+	return configs
+}
+
+// sweepRun is a training run of a configuration of a sweep, with a seed.
+type sweepRun struct {
+	Config int           // index of the configuration
+	Seed   int64         // random seed
+	Dir    string        // directory of the run
+	Result *RunResult    // result, if the run succeeded
+	Time   time.Duration // wall time of the run
+	Err    error         // error of the run, if any
+}
+
+// prepare writes the configurations of the runs of the sweep to their
+// directories, and validates them, before any run starts. The fields of the
+// argument base configuration are overridden by the swept values.
+func (s *Sweep) prepare(base *Configuration,
+	configs []map[string]interface{}) ([]*sweepRun, error) {
+	// runs are in their own directories
+	var err error
+	for _, path := range []*string{&base.StorePath, &base.RenderWarp} {
+		if *path != "" {
+			if *path, err = filepath.Abs(*path); err != nil {
+				return nil, err
+			}
+		}
+	}
+	data, err := json.Marshal(base)
+	if err != nil {
+		return nil, err
+	}
+
+	runs := make([]*sweepRun, 0, len(configs)*s.NumSeeds)
+	for i, values := range configs {
+		for j := 0; j < s.NumSeeds; j++ {
+			fields := make(map[string]interface{})
+			if err := json.Unmarshal(data, &fields); err != nil {
+				return nil, err
+			}
+			for name, v := range values {
+				fields[name] = v
+			}
+			r := &sweepRun{
+				Config: i,
+				Seed:   base.Seed + int64(j),
+				Dir: filepath.Join(s.Directory,
+					fmt.Sprintf("config_%03d_seed_%d", i, base.Seed+int64(j))),
+			}
+			fields["Seed"] = r.Seed
+
+			if err := os.MkdirAll(r.Dir, 0755); err != nil {
+				return nil, err
+			}
+			config, err := json.MarshalIndent(fields, "", "\t")
+			if err != nil {
+				return nil, err
+			}
+			filename := filepath.Join(r.Dir, "config.json")
+			if err := os.WriteFile(filename, config, 0644); err != nil {
+				return nil, err
+			}
+			if _, err := NewConfiguration(filename); err != nil {
+				return nil, err
+			}
+			runs = append(runs, r)
+		}
+	}
+	return runs, nil
+}
+
+// run trains the configuration of the run on the argument image, in a new
+// process in the run's directory, with the output in output.txt.
+func (r *sweepRun) run(executable, imgFile string) {
+	start := time.Now()
+	defer func() {
+		r.Time = time.Since(start)
+	}()
+
+	output, err := os.Create(filepath.Join(r.Dir, "output.txt"))
+	if err != nil {
+		r.Err = err
+		return
+	}
+	defer output.Close()
+
+	cmd := exec.Command(executable, imgFile, "config.json", "result.json")
+	cmd.Dir = r.Dir
+	cmd.Stdout, cmd.Stderr = output, output
+	if err := cmd.Run(); err != nil {
+		r.Err = fmt.Errorf("%s: %s", r.Dir, err)
+		return
+	}
+
+	data, err := os.ReadFile(filepath.Join(r.Dir, "result.json"))
+	if err != nil {
+		r.Err = err
+		return
+	}
+	r.Result = &RunResult{}
+	if err := json.Unmarshal(data, r.Result); err != nil {
+		r.Result, r.Err = nil, err
+	}
+}
+
+// Summary returns a table of the swept values of each configuration, and the
+// mean and standard deviation of the best scores of its successful runs,
+// with their mean wall time and size of the best genome.
+func (s *Sweep) Summary(configs []map[string]interface{},
+	runs []*sweepRun) string {
+	var b bytes.Buffer
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', tabwriter.AlignRight)
+	names := s.names()
+	fmt.Fprintf(w, "Config\t%s\tRuns\tBest score\tStd dev\tTime (s)\t"+
+		"Nodes\tEdges\t\n", strings.Join(names, "\t"))
+
+	for i, values := range configs {
+		fmt.Fprintf(w, "%d\t", i)
+		for _, name := range names {
+			switch v := values[name].(type) {
+			case float64:
+				fmt.Fprintf(w, "%.4g\t", v)
+			default:
+				fmt.Fprintf(w, "%v\t", v)
+			}
+		}
+
+		scores := make([]float64, 0)
+		seconds, nodes, edges := 0.0, 0.0, 0.0
+		for _, r := range runs {
+			if r.Config != i || r.Result == nil {
+				continue
+			}
+			scores = append(scores, r.Result.BestScore)
+			seconds += r.Time.Seconds()
+			nodes += float64(r.Result.NumNodes)
+			edges += float64(r.Result.NumEdges)
+		}
+		if len(scores) == 0 {
+			fmt.Fprintf(w, "0\t-\t-\t-\t-\t-\t\n")
+			continue
+		}
+
+		n := float64(len(scores))
+		mean, variance := 0.0, 0.0
+		for _, score := range scores {
+			mean += score / n
+		}
+		for _, score := range scores {
+			variance += (score - mean) * (score - mean) / n
+		}
+		fmt.Fprintf(w, "%d\t%f\t%f\t%.1f\t%.1f\t%.1f\t\n", len(scores), mean,
+			math.Sqrt(variance), seconds/n, nodes/n, edges/n)
+	}
+	w.Flush()
+	return b.String()
+}
+
+// sweep trains the configurations of a sweep on the argument image, with
+// concurrent runs in their own processes and directories, and prints a
+// summary of the results of each configuration.
+func sweep(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: imagen sweep [filename].png [sweep].json")
+	}
+
+	imgFile, err := filepath.Abs(args[0])
+	if err != nil {
+		return err
+	}
+	s, err := NewSweep(args[1])
+	if err != nil {
+		return err
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	// random search is reproducible, without affecting the global source
+	base, err := NewConfiguration(s.Base)
+	if err != nil {
+		return err
+	}
+	configs := s.Configurations(rand.New(rand.NewSource(base.Seed)))
+	runs, err := s.prepare(base, configs)
+	if err != nil {
+		return err
+	}
+
+	jobs := make(chan *sweepRun)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	numDone := 0
+	for i := 0; i < s.NumWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range jobs {
+				r.run(executable, imgFile)
+
+				mu.Lock()
+				numDone++
+				if r.Err != nil {
+					fmt.Printf("Run [%4d / %4d] | %s failed: %s\n", numDone,
+						len(runs), r.Dir, r.Err)
+				} else {
+					fmt.Printf("Run [%4d / %4d] | %s | best score: %f "+
+						"(%.1fs)\n", numDone, len(runs), r.Dir,
+						r.Result.BestScore, r.Time.Seconds())
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, r := range runs {
+		jobs <- r
+	}
+	close(jobs)
+	wg.Wait()
+
+	fmt.Print(s.Summary(configs, runs))
+
+	numFailed := 0
+	for _, r := range runs {
+		if r.Err != nil {
+			numFailed++
+		}
+	}
+	if numFailed > 0 {
+		return fmt.Errorf("%d of %d runs failed", numFailed, len(runs))
+	}
+	return nil
+}
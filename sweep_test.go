@@ -0,0 +1,170 @@
+/*
+
+
+sweep_test.go tests for hyperparameter sweeps.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSweepConfigurations(t *testing.T) {
+	s := &Sweep{
+		Base:       "config.json",
+		Method:     "grid",
+		NumSeeds:   1,
+		NumWorkers: 1,
+		Parameters: map[string]SweepParameter{
+			"LearningRate":   {Min: 0.01, Max: 1.0, Steps: 3, Log: true},
+			"BatchSize":      {Values: []interface{}{8.0, 16.0}},
+			"PopulationSize": {Min: 10, Max: 15, Steps: 2},
+		},
+	}
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// grid search has every combination of values
+	configs := s.Configurations(nil)
+	if len(configs) != 12 {
+		t.Fatalf("%d configurations, expected 12", len(configs))
+	}
+	seen := make(map[string]bool)
+	for _, c := range configs {
+		key := fmt.Sprintf("%v %.3g %v", c["BatchSize"], c["LearningRate"],
+			c["PopulationSize"])
+		seen[key] = true
+		if _, ok := c["PopulationSize"].(int); !ok {
+			t.Errorf("PopulationSize is %v, not an integer", c["PopulationSize"])
+		}
+	}
+	for _, key := range []string{"8 0.01 10", "16 0.1 15", "16 1 10"} {
+		if !seen[key] {
+			t.Errorf("configuration %s is not in the grid", key)
+		}
+	}
+
+	// random search samples values in the ranges
+	s.Method, s.NumSamples = "random", 20
+	configs = s.Configurations(rand.New(rand.NewSource(0)))
+	if len(configs) != 20 {
+		t.Fatalf("%d configurations, expected 20", len(configs))
+	}
+	for _, c := range configs {
+		lr := c["LearningRate"].(float64)
+		size := c["PopulationSize"].(int)
+		if lr < 0.01 || lr > 1.0 || size < 10 || size > 15 {
+			t.Errorf("configuration %v is out of range", c)
+		}
+	}
+
+	for _, p := range []map[string]SweepParameter{
+		{"Seed": {Values: []interface{}{1.0}}},
+		{"RenderPath": {Values: []interface{}{nil}}},
+		{"LearningRat": {Min: 0.1, Max: 1.0}},
+		{"LearningRate": {Min: 0.0, Max: 1.0, Log: true}},
+		{"ColorSpace": {Min: 0.0, Max: 1.0}},
+	} {
+		s.Parameters = p
+		if err := s.Validate(); err == nil {
+			t.Errorf("sweep of %v is valid", p)
+		}
+	}
+}
+
+func TestSweepPrepare(t *testing.T) {
+	dir := t.TempDir()
+	s := &Sweep{
+		Base:      filepath.Join("tests", "cup", "config.json"),
+		Method:    "grid",
+		NumSeeds:  2,
+		Directory: dir,
+		Parameters: map[string]SweepParameter{
+			"BatchSize": {Values: []interface{}{4.0, 0.5}},
+		},
+	}
+	base, err := NewConfiguration(s.Base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// configurations are validated before any run
+	configs := s.Configurations(nil)
+	if _, err := s.prepare(base, configs); err == nil {
+		t.Error("batch size of 0.5 is valid")
+	}
+
+	s.Parameters["BatchSize"] = SweepParameter{Values: []interface{}{4.0}}
+	configs = s.Configurations(nil)
+	runs, err := s.prepare(base, configs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[1].Seed != base.Seed+1 {
+		t.Fatalf("runs are %v, expected 2 seeds", runs)
+	}
+	config, err := NewConfiguration(filepath.Join(runs[1].Dir, "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.BatchSize != 4 || config.Seed != base.Seed+1 ||
+		config.NumOutputs != base.NumOutputs {
+		t.Errorf("configuration of run %s is %+v", runs[1].Dir, config)
+	}
+
+	// the summary has the mean and standard deviation of the best scores
+	runs[0].Result = &RunResult{BestScore: 1.0, NumNodes: 10, NumEdges: 20}
+	runs[1].Result = &RunResult{BestScore: 3.0, NumNodes: 12, NumEdges: 22}
+	runs[0].Time, runs[1].Time = time.Second, 3*time.Second
+	summary := s.Summary(configs, runs)
+	lines := strings.Split(strings.TrimSpace(summary), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("summary is\n%s", summary)
+	}
+	fields := strings.Fields(lines[1])
+	expected := []string{"0", "4", "2", "2.000000", "1.000000", "2.0", "11.0",
+		"21.0"}
+	if strings.Join(fields, " ") != strings.Join(expected, " ") {
+		t.Errorf("summary is %v, expected %v", fields, expected)
+	}
+	if _, err := os.Stat(filepath.Join(runs[0].Dir, "config.json")); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,129 @@
+/*
+
+
+symmetry.go implementation of symmetry folding of coordinates.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Symmetry is a symmetry that is enforced by folding coordinates about the
+// center of the pixel grid, before they are encoded into a DPPN's inputs.
+type Symmetry struct {
+	Kind  string // none, mirror-x, mirror-y, mirror-xy, rotate or kaleidoscope
+	Order int    // number of rotations of rotate and kaleidoscope symmetry
+}
+
+// ParseSymmetry parses a symmetry: none (or empty), mirror-x, mirror-y,
+// mirror-xy, rotate-N for N-fold rotational symmetry, or kaleidoscope-N for
+// N-fold rotational symmetry with mirrors.
+func ParseSymmetry(s string) (Symmetry, error) {
+	switch s {
+	case "", "none":
+		return Symmetry{Kind: "none"}, nil
+	case "mirror-x", "mirror-y", "mirror-xy":
+		return Symmetry{Kind: s}, nil
+	}
+
+	i := strings.LastIndex(s, "-")
+	if i < 0 {
+		return Symmetry{}, fmt.Errorf("unknown symmetry %s", s)
+	}
+	kind := s[:i]
+	if kind != "rotate" && kind != "kaleidoscope" {
+		return Symmetry{}, fmt.Errorf("unknown symmetry %s", s)
+	}
+	order, err := strconv.Atoi(s[i+1:])
+	if err != nil || order < 1 {
+		return Symmetry{}, fmt.Errorf("invalid order of symmetry %s", s)
+	}
+	return Symmetry{Kind: kind, Order: order}, nil
+}
+
+// String returns the symmetry in the format read by ParseSymmetry.
+func (s Symmetry) String() string {
+	switch s.Kind {
+	case "rotate", "kaleidoscope":
+		return fmt.Sprintf("%s-%d", s.Kind, s.Order)
+	case "":
+		return "none"
+	}
+	return s.Kind
+}
+
+// gridCenter returns the center of a grid of the argument number of pixels
+// spanning a domain of the argument size, whose pixels are sampled at their
+// top left corners, as in training. Folding about it makes each pixel x the
+// mirror of pixel n - 1 - x, so that renders are symmetric under their own
+// flips.
+func gridCenter(domain float64, pixels int) float64 {
+	return (float64(pixels) - 1.0) / 2.0 * domain / float64(pixels)
+}
+
+// Fold maps a coordinate (x, y) to its representative under the symmetry
+// about the argument center, so that all symmetric coordinates are encoded
+// into the same inputs. Mirrors fold the left (or top) half onto the right
+// (or bottom) half. Rotations fold every wedge of 2 pi / N radians about the
+// center onto the first wedge, and the kaleidoscope also mirrors each wedge
+// about its bisector.
+func (s Symmetry) Fold(x, y, cx, cy float64) (float64, float64) {
+	switch s.Kind {
+	case "mirror-x":
+		return cx + math.Abs(x-cx), y
+	case "mirror-y":
+		return x, cy + math.Abs(y-cy)
+	case "mirror-xy":
+		return cx + math.Abs(x-cx), cy + math.Abs(y-cy)
+	case "rotate", "kaleidoscope":
+		dx, dy := x-cx, y-cy
+		r := math.Sqrt(dx*dx + dy*dy)
+		a := math.Atan2(dy, dx)
+		w := s.wedge()
+		a -= w * math.Floor(a/w)
+		if s.Kind == "kaleidoscope" {
+			a = math.Min(a, w-a)
+		}
+		return cx + r*math.Cos(a), cy + r*math.Sin(a)
+	}
+	return x, y
+}
+
+// wedge returns the angle of each wedge of rotate and kaleidoscope symmetry.
+func (s Symmetry) wedge() float64 {
+	return 2.0 * math.Pi / float64(s.Order)
+}
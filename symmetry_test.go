@@ -0,0 +1,166 @@
+/*
+
+
+symmetry_test.go tests for symmetry folding.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestParseSymmetry(t *testing.T) {
+	for _, s := range []string{"none", "mirror-x", "mirror-y", "mirror-xy",
+		"rotate-3", "kaleidoscope-6"} {
+		symmetry, err := ParseSymmetry(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if symmetry.String() != s {
+			t.Errorf("symmetry %s is parsed as %s", s, symmetry)
+		}
+	}
+	if symmetry, err := ParseSymmetry(""); err != nil || symmetry.Kind != "none" {
+		t.Errorf("empty symmetry is parsed as %s, %v", symmetry, err)
+	}
+	for _, s := range []string{"mirror", "rotate", "rotate-0", "rotate-x",
+		"spiral-3"} {
+		if _, err := ParseSymmetry(s); err == nil {
+			t.Errorf("invalid symmetry %s is parsed", s)
+		}
+	}
+}
+
+func TestSymmetryFold(t *testing.T) {
+	rand.Seed(0)
+
+	const width, height = 20.0, 10.0
+	cx, cy := width/2.0, height/2.0
+	near := func(x0, y0, x1, y1 float64) bool {
+		return math.Abs(x0-x1) < 1e-9 && math.Abs(y0-y1) < 1e-9
+	}
+	for i := 0; i < 100; i++ {
+		x, y := rand.Float64()*width, rand.Float64()*height
+
+		s := Symmetry{Kind: "mirror-xy"}
+		x0, y0 := s.Fold(x, y, cx, cy)
+		x1, y1 := s.Fold(width-x, height-y, cx, cy)
+		if !near(x0, y0, x1, y1) || x0 < cx || y0 < cy {
+			t.Errorf("mirror-xy folds (%f, %f) to (%f, %f) and (%f, %f)",
+				x, y, x0, y0, x1, y1)
+		}
+
+		// rotating about the center by a wedge folds to the same coordinate,
+		// and so does mirroring about the first wedge's edge for kaleidoscopes
+		for _, s := range []Symmetry{{"rotate", 5}, {"kaleidoscope", 5}} {
+			w := s.wedge()
+			x0, y0 := s.Fold(x, y, cx, cy)
+			dx, dy := x-cx, y-cy
+			x1, y1 := s.Fold(cx+dx*math.Cos(w)-dy*math.Sin(w),
+				cy+dx*math.Sin(w)+dy*math.Cos(w), cx, cy)
+			if !near(x0, y0, x1, y1) {
+				t.Errorf("%s folds (%f, %f) to (%f, %f), but its rotation "+
+					"to (%f, %f)", s, x, y, x0, y0, x1, y1)
+			}
+			if s.Kind == "kaleidoscope" {
+				x1, y1 = s.Fold(x, height-y, cx, cy)
+				if !near(x0, y0, x1, y1) {
+					t.Errorf("%s folds (%f, %f) to (%f, %f), but its mirror "+
+						"to (%f, %f)", s, x, y, x0, y0, x1, y1)
+				}
+			}
+		}
+	}
+}
+
+func TestRenderSymmetry(t *testing.T) {
+	rand.Seed(0)
+
+	g := NewGenome(0, 4, 4, 3)
+	for i := 0; i < 20; i++ {
+		g.Mutate(0.5, 0.5)
+	}
+
+	// symmetry is folded about the center of the pixel grid, so that pixel x
+	// mirrors pixel w - 1 - x, and rotations map the grid onto itself
+	const size = 64
+	opts := NewRenderOptions(size, size)
+	partners := map[string]func(x, y int) [][2]int{
+		"mirror-x": func(x, y int) [][2]int {
+			return [][2]int{{size - 1 - x, y}}
+		},
+		"mirror-xy": func(x, y int) [][2]int {
+			return [][2]int{{size - 1 - x, y}, {x, size - 1 - y}}
+		},
+		"rotate-2": func(x, y int) [][2]int {
+			return [][2]int{{size - 1 - x, size - 1 - y}}
+		},
+		"rotate-4": func(x, y int) [][2]int {
+			return [][2]int{{size - 1 - y, x}}
+		},
+	}
+	for name, partner := range partners {
+		var err error
+		if opts.Symmetry, err = ParseSymmetry(name); err != nil {
+			t.Fatal(err)
+		}
+		img := render(g, opts)
+		numMismatched := 0
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				c := img.RGBAAt(x, y)
+				for _, p := range partner(x, y) {
+					d := img.RGBAAt(p[0], p[1])
+					// rotations may round differently at channel boundaries
+					if absDiff(c.R, d.R) > 1 || absDiff(c.G, d.G) > 1 ||
+						absDiff(c.B, d.B) > 1 ||
+						(strings.HasPrefix(name, "mirror") && c != d) {
+						numMismatched++
+					}
+				}
+			}
+		}
+		if numMismatched > 0 {
+			t.Errorf("%d pixels of %s differ from their mirror images",
+				numMismatched, name)
+		}
+	}
+
+	opts.Symmetry = Symmetry{Kind: "rotate"}
+	if err := opts.Validate(); err == nil {
+		t.Error("rotational symmetry without an order is valid")
+	}
+}
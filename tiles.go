@@ -0,0 +1,463 @@
+/*
+
+
+tiles.go implementation of tiled TIFF files and deep zoom pyramids.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Tile sizes of tiled outputs.
+const (
+	tiffTileSize = 256 // width and height of TIFF tiles, a multiple of 16
+	dziTileSize  = 254 // width and height of deep zoom tiles, without overlap
+	dziOverlap   = 1   // overlap of deep zoom tiles with their neighbors
+)
+
+// TIFF field types.
+const (
+	tiffShort = 3
+	tiffLong  = 4
+	tiffLong8 = 16
+)
+
+// tiffEntry is an entry of a TIFF image file directory, with its values
+// encoded in little-endian byte order.
+type tiffEntry struct {
+	Tag   uint16
+	Type  uint16
+	Count uint64
+	Data  []byte
+}
+
+// tiffValues returns a TIFF entry of integers of the argument type.
+func tiffValues(tag, typ uint16, values ...uint64) tiffEntry {
+	var b []byte
+	for _, v := range values {
+		switch typ {
+		case tiffShort:
+			b = binary.LittleEndian.AppendUint16(b, uint16(v))
+		case tiffLong:
+			b = binary.LittleEndian.AppendUint32(b, uint32(v))
+		case tiffLong8:
+			b = binary.LittleEndian.AppendUint64(b, v)
+		}
+	}
+	return tiffEntry{tag, typ, uint64(len(values)), b}
+}
+
+// tiffLayout is the layout of a TIFF file with a single image file
+// directory, followed by the values that do not fit in their entries, and by
+// the image data.
+type tiffLayout struct {
+	Big bool // whether the file is a BigTIFF file
+}
+
+// sizes returns the size of the header, of an entry, and of the value field
+// of an entry.
+func (l *tiffLayout) sizes() (header, entry, value uint64) {
+	if l.Big {
+		return 16, 20, 8
+	}
+	return 8, 12, 4
+}
+
+// ifdSize returns the size of an image file directory with the argument
+// entries, without the values that do not fit in their entries.
+func (l *tiffLayout) ifdSize(entries []tiffEntry) uint64 {
+	_, entry, value := l.sizes()
+	count := uint64(2)
+	if l.Big {
+		count = 8
+	}
+	return count + uint64(len(entries))*entry + value
+}
+
+// write writes the header and the image file directory.
+func (l *tiffLayout) write(w io.Writer, entries []tiffEntry) error {
+	header, _, value := l.sizes()
+	var b bytes.Buffer
+	le := binary.LittleEndian
+
+	// header, with the directory right after it
+	b.WriteString("II")
+	if l.Big {
+		b.Write(le.AppendUint16(nil, 43))
+		b.Write(le.AppendUint16(nil, 8))
+		b.Write(le.AppendUint16(nil, 0))
+		b.Write(le.AppendUint64(nil, header))
+	} else {
+		b.Write(le.AppendUint16(nil, 42))
+		b.Write(le.AppendUint32(nil, uint32(header)))
+	}
+
+	// entries, with values that do not fit after the directory
+	var external bytes.Buffer
+	externalStart := header + l.ifdSize(entries)
+	if l.Big {
+		b.Write(le.AppendUint64(nil, uint64(len(entries))))
+	} else {
+		b.Write(le.AppendUint16(nil, uint16(len(entries))))
+	}
+	for _, e := range entries {
+		b.Write(le.AppendUint16(nil, e.Tag))
+		b.Write(le.AppendUint16(nil, e.Type))
+		field := make([]byte, value)
+		if uint64(len(e.Data)) <= value {
+			copy(field, e.Data)
+		} else {
+			offset := externalStart + uint64(external.Len())
+			if l.Big {
+				le.PutUint64(field, offset)
+			} else {
+				le.PutUint32(field, uint32(offset))
+			}
+			external.Write(e.Data)
+			if len(e.Data)%2 == 1 {
+				external.WriteByte(0)
+			}
+		}
+		if l.Big {
+			b.Write(le.AppendUint64(nil, e.Count))
+		} else {
+			b.Write(le.AppendUint32(nil, uint32(e.Count)))
+		}
+		b.Write(field)
+	}
+	b.Write(make([]byte, value)) // no next directory
+	b.Write(external.Bytes())
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// externalSize returns the size of the values that do not fit in their
+// entries.
+func (l *tiffLayout) externalSize(entries []tiffEntry) uint64 {
+	_, _, value := l.sizes()
+	size := uint64(0)
+	for _, e := range entries {
+		if n := uint64(len(e.Data)); n > value {
+			size += n + n%2
+		}
+	}
+	return size
+}
+
+// WriteTiledTIFF renders an image, given render options, and writes it as
+// an uncompressed tiled RGB TIFF file, with premultiplied alpha if the color
+// space has an alpha output, rendering and writing a tile at a time, so that
+// the memory used does not depend on the size of the image. Files larger
+// than 4 GiB are written as BigTIFF.
+func WriteTiledTIFF(w io.Writer, r *Renderer, opts *RenderOptions) error {
+	if opts.Width <= 0 || opts.Height <= 0 {
+		return fmt.Errorf("invalid image size %d x %d", opts.Width, opts.Height)
+	}
+	across := (opts.Width + tiffTileSize - 1) / tiffTileSize
+	down := (opts.Height + tiffTileSize - 1) / tiffTileSize
+	numTiles := uint64(across * down)
+	tileBytes := uint64(tiffTileSize * tiffTileSize * tiffSamples(r, opts))
+
+	big := numTiles*tileBytes+numTiles*16 > math.MaxUint32
+	return writeTiledTIFF(w, r, opts, big)
+}
+
+// tiffSamples returns the number of samples per pixel of TIFF files: RGB, and
+// alpha if the renderer's DPPN has an alpha output.
+func tiffSamples(r *Renderer, opts *RenderOptions) int {
+	if _, alpha, _ := opts.ColorSpace.Channels(r.NumOutputs); alpha {
+		return 4
+	}
+	return 3
+}
+
+// writeTiledTIFF writes a tiled TIFF file as in WriteTiledTIFF, as a BigTIFF
+// file if big is set.
+func writeTiledTIFF(w io.Writer, r *Renderer, opts *RenderOptions,
+	big bool) error {
+	across := (opts.Width + tiffTileSize - 1) / tiffTileSize
+	down := (opts.Height + tiffTileSize - 1) / tiffTileSize
+	numTiles := across * down
+	samples := tiffSamples(r, opts)
+	tileBytes := uint64(tiffTileSize * tiffTileSize * samples)
+
+	layout := &tiffLayout{Big: big}
+	offsetType := uint16(tiffLong)
+	if layout.Big {
+		offsetType = tiffLong8
+	}
+
+	bits := []uint64{8, 8, 8, 8}[:samples]
+	entries := func(offsets, counts []uint64) []tiffEntry {
+		e := []tiffEntry{
+			tiffValues(256, tiffLong, uint64(opts.Width)),  // ImageWidth
+			tiffValues(257, tiffLong, uint64(opts.Height)), // ImageLength
+			tiffValues(258, tiffShort, bits...),            // BitsPerSample
+			tiffValues(259, tiffShort, 1),                  // Compression
+			tiffValues(262, tiffShort, 2),                  // Photometric (RGB)
+			tiffValues(277, tiffShort, uint64(samples)),    // SamplesPerPixel
+			tiffValues(284, tiffShort, 1),                  // PlanarConfig
+			tiffValues(322, tiffShort, tiffTileSize),       // TileWidth
+			tiffValues(323, tiffShort, tiffTileSize),       // TileLength
+			tiffValues(324, offsetType, offsets...),        // TileOffsets
+			tiffValues(325, offsetType, counts...),         // TileByteCounts
+		}
+		if samples == 4 {
+			// ExtraSamples (associated alpha)
+			e = append(e, tiffValues(338, tiffShort, 1))
+		}
+		return e
+	}
+	offsets := make([]uint64, numTiles)
+	counts := make([]uint64, numTiles)
+	header, _, _ := layout.sizes()
+	dataStart := header + layout.ifdSize(entries(offsets, counts)) +
+		layout.externalSize(entries(offsets, counts))
+	for i := range offsets {
+		offsets[i] = dataStart + uint64(i)*tileBytes
+		counts[i] = tileBytes
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := layout.write(bw, entries(offsets, counts)); err != nil {
+		return err
+	}
+
+	// tiles in row-major order, padded to the full tile size
+	tile := make([]byte, tileBytes)
+	bounds := image.Rect(0, 0, opts.Width, opts.Height)
+	for ty := 0; ty < down; ty++ {
+		for tx := 0; tx < across; tx++ {
+			rect := image.Rect(tx*tiffTileSize, ty*tiffTileSize,
+				(tx+1)*tiffTileSize, (ty+1)*tiffTileSize).Intersect(bounds)
+			img := r.RenderRect(opts, rect)
+
+			for i := range tile {
+				tile[i] = 0
+			}
+			for y := 0; y < rect.Dy(); y++ {
+				for x := 0; x < rect.Dx(); x++ {
+					src := img.PixOffset(rect.Min.X+x, rect.Min.Y+y)
+					dst := samples * (y*tiffTileSize + x)
+					copy(tile[dst:dst+samples], img.Pix[src:src+samples])
+				}
+			}
+			if _, err := bw.Write(tile); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// WriteDZI renders an image, given render options, as a deep zoom image: a
+// descriptor at the argument path (name.dzi), and a pyramid of PNG tiles in
+// name_files/[level]/[column]_[row].png. Since the DPPN is resolution-free,
+// each level is rendered directly at its resolution, a tile at a time.
+func WriteDZI(path string, r *Renderer, opts *RenderOptions) error {
+	if opts.Width <= 0 || opts.Height <= 0 {
+		return fmt.Errorf("invalid image size %d x %d", opts.Width, opts.Height)
+	}
+	dir := strings.TrimSuffix(path, filepath.Ext(path)) + "_files"
+
+	maxSize := math.Max(float64(opts.Width), float64(opts.Height))
+	maxLevel := int(math.Ceil(math.Log2(maxSize)))
+	for level := maxLevel; level >= 0; level-- {
+		scale := math.Pow(2.0, float64(maxLevel-level))
+		levelOpts := *opts
+		levelOpts.Width = int(math.Ceil(float64(opts.Width) / scale))
+		levelOpts.Height = int(math.Ceil(float64(opts.Height) / scale))
+
+		levelDir := filepath.Join(dir, strconv.Itoa(level))
+		if err := os.MkdirAll(levelDir, 0755); err != nil {
+			return err
+		}
+
+		bounds := image.Rect(0, 0, levelOpts.Width, levelOpts.Height)
+		for row := 0; row*dziTileSize < levelOpts.Height; row++ {
+			for col := 0; col*dziTileSize < levelOpts.Width; col++ {
+				rect := image.Rect(col*dziTileSize-dziOverlap,
+					row*dziTileSize-dziOverlap, (col+1)*dziTileSize+dziOverlap,
+					(row+1)*dziTileSize+dziOverlap).Intersect(bounds)
+				img := r.RenderRect(&levelOpts, rect)
+
+				name := filepath.Join(levelDir, fmt.Sprintf("%d_%d.png", col, row))
+				if err := writePNG(name, img); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	descriptor := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<Image xmlns="http://schemas.microsoft.com/deepzoom/2008"
+  Format="png" Overlap="%d" TileSize="%d">
+  <Size Width="%d" Height="%d"/>
+</Image>
+`, dziOverlap, dziTileSize, opts.Width, opts.Height)
+	return os.WriteFile(path, []byte(descriptor), 0644)
+}
+
+// writePNG writes the argument image as a PNG file.
+func writePNG(filename string, img image.Image) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// trainingDomain returns the size of the coordinate domain that the genome of
+// the argument metadata was trained on, or the argument size if it is not
+// recorded.
+func trainingDomain(meta *GenomeMeta, width, height int) (float64, float64) {
+	if meta.Config != nil && meta.Config.Width > 0 && meta.Config.Height > 0 {
+		width, height = meta.Config.Width, meta.Config.Height
+	}
+	return float64(width), float64(height)
+}
+
+// parseDomain parses the size of a coordinate domain, [width]x[height].
+func parseDomain(s string) (float64, float64, error) {
+	var width, height int
+	if _, err := fmt.Sscanf(s, "%dx%d", &width, &height); err != nil ||
+		width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("invalid domain %q, expected [width]x[height]",
+			s)
+	}
+	return float64(width), float64(height), nil
+}
+
+// renderCommand renders an exported genome at the argument size, as a PNG
+// image, a floating-point PFM image, a tiled TIFF file, or a deep zoom image,
+// given by the output file's extension. The coordinate domain is the size the
+// genome was trained at, unless it is given by the -domain flag, so that
+// larger renders are of the same image at a higher resolution. Supersampling
+// and the viewport are read from the configuration, if any. The input
+// encoding and symmetry are the configuration's, or else the genome's.
+func renderCommand(args []string) error {
+	flags := flag.NewFlagSet("render", flag.ContinueOnError)
+	domain := flags.String("domain", "",
+		"size of the coordinate domain, [width]x[height]")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	args = flags.Args()
+	if len(args) != 4 && len(args) != 5 {
+		return errors.New("usage: imagen render [-domain [width]x[height]] " +
+			"[genome] [output](.png|.pfm|.tif|.tiff|.dzi) [width] [height] " +
+			"[[config].json]")
+	}
+
+	g, meta, err := OpenGenome(args[0])
+	if err != nil {
+		return err
+	}
+	width, err := strconv.Atoi(args[2])
+	if err != nil {
+		return err
+	}
+	height, err := strconv.Atoi(args[3])
+	if err != nil {
+		return err
+	}
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("invalid image size %d x %d", width, height)
+	}
+
+	config := &Configuration{}
+	if len(args) == 5 {
+		if config, err = NewConfiguration(args[4]); err != nil {
+			return err
+		}
+	}
+	config.inherit(meta)
+	opts, err := config.FinalRenderOptions(width, height)
+	if err != nil {
+		return err
+	}
+	if err := opts.ColorSpace.Validate(g.NumOutputs); err != nil {
+		return err
+	}
+	opts.DomainWidth, opts.DomainHeight = trainingDomain(meta, width, height)
+	if *domain != "" {
+		opts.DomainWidth, opts.DomainHeight, err = parseDomain(*domain)
+		if err != nil {
+			return err
+		}
+	}
+
+	r, err := NewRenderer(g, 0)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(args[1])) {
+	case ".png":
+		return writePNG(args[1], r.RenderImage(opts))
+	case ".pfm":
+		return writePFM(args[1], r.RenderFloat(opts))
+	case ".tif", ".tiff":
+		f, err := os.Create(args[1])
+		if err != nil {
+			return err
+		}
+		if err := WriteTiledTIFF(f, r, opts); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	case ".dzi":
+		return WriteDZI(args[1], r, opts)
+	}
+	return fmt.Errorf("unknown image format of %s", args[1])
+}
@@ -0,0 +1,267 @@
+/*
+
+
+tiles_test.go tests for tiled rendering.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// readTiledTIFF reads the tags of a tiled TIFF file written by
+// writeTiledTIFF, and decodes its tiles into an image.
+func readTiledTIFF(b []byte) (*image.RGBA, error) {
+	le := binary.LittleEndian
+	if string(b[:2]) != "II" {
+		return nil, fmt.Errorf("not a little-endian TIFF file")
+	}
+	big := le.Uint16(b[2:]) == 43
+
+	// offset of the first entry, number of entries, and sizes of an entry
+	// and its value field
+	var offset, count, entry, value uint64
+	if big {
+		offset = le.Uint64(b[8:])
+		count = le.Uint64(b[offset:])
+		offset, entry, value = offset+8, 20, 8
+	} else {
+		offset = uint64(le.Uint32(b[4:]))
+		count = uint64(le.Uint16(b[offset:]))
+		offset, entry, value = offset+2, 12, 4
+	}
+
+	tags := make(map[uint16][]uint64)
+	for i := uint64(0); i < count; i++ {
+		e := b[offset+i*entry:]
+		tag, typ := le.Uint16(e), le.Uint16(e[2:])
+		var n uint64
+		var data []byte
+		if big {
+			n, data = le.Uint64(e[4:]), e[12:]
+		} else {
+			n, data = uint64(le.Uint32(e[4:])), e[8:]
+		}
+		size := map[uint16]uint64{tiffShort: 2, tiffLong: 4, tiffLong8: 8}[typ]
+		if n*size > value {
+			var at uint64
+			if big {
+				at = le.Uint64(data)
+			} else {
+				at = uint64(le.Uint32(data))
+			}
+			data = b[at:]
+		}
+		for j := uint64(0); j < n; j++ {
+			switch typ {
+			case tiffShort:
+				tags[tag] = append(tags[tag], uint64(le.Uint16(data[2*j:])))
+			case tiffLong:
+				tags[tag] = append(tags[tag], uint64(le.Uint32(data[4*j:])))
+			case tiffLong8:
+				tags[tag] = append(tags[tag], le.Uint64(data[8*j:]))
+			}
+		}
+	}
+
+	width, height := int(tags[256][0]), int(tags[257][0])
+	tileWidth, tileHeight := int(tags[322][0]), int(tags[323][0])
+	across := (width + tileWidth - 1) / tileWidth
+	samples := int(tags[277][0])
+	if len(tags[258]) != samples || tags[259][0] != 1 || tags[262][0] != 2 {
+		return nil, fmt.Errorf("unexpected tags %v", tags)
+	}
+	if samples == 4 && (len(tags[338]) != 1 || tags[338][0] != 1) {
+		return nil, fmt.Errorf("alpha is not associated in tags %v", tags)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i, at := range tags[324] {
+		tile := b[at : at+tags[325][i]]
+		x0, y0 := (i%across)*tileWidth, (i/across)*tileHeight
+		for y := 0; y < tileHeight && y0+y < height; y++ {
+			for x := 0; x < tileWidth && x0+x < width; x++ {
+				p := tile[samples*(y*tileWidth+x):]
+				j := img.PixOffset(x0+x, y0+y)
+				img.Pix[j+3] = 255
+				copy(img.Pix[j:j+samples], p[:samples])
+			}
+		}
+	}
+	return img, nil
+}
+
+func TestWriteTiledTIFF(t *testing.T) {
+	rand.Seed(0)
+
+	g := NewGenome(0, 4, 4, 3)
+	for i := 0; i < 20; i++ {
+		g.Mutate(0.5, 0.5)
+	}
+	r, err := NewRenderer(g, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := NewRenderOptions(300, 270)
+
+	for i, big := range []bool{false, true, false} {
+		if i == 2 {
+			// two palette outputs, followed by an alpha output
+			opts.ColorSpace = ColorSpace{Name: "palette",
+				Palette: []color.RGBA{{255, 0, 0, 255}, {0, 0, 255, 255}}}
+		}
+		expected := r.Render(opts)
+
+		var buf bytes.Buffer
+		if err := writeTiledTIFF(&buf, r, opts, big); err != nil {
+			t.Fatal(err)
+		}
+		img, err := readTiledTIFF(buf.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(img.Pix, expected.Pix) {
+			t.Errorf("tiled TIFF (big %v) differs from the rendered image", big)
+		}
+	}
+}
+
+func TestWriteDZI(t *testing.T) {
+	rand.Seed(0)
+
+	g := NewGenome(0, 4, 4, 3)
+	r, err := NewRenderer(g, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := WriteDZI(filepath.Join(dir, "out.dzi"), r,
+		NewRenderOptions(300, 200)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 300 x 200 has levels 0 (1 x 1) to 9 (300 x 200), with 2 x 1 tiles in
+	// the largest level
+	tiles := map[string]image.Rectangle{
+		"0/0_0.png": image.Rect(0, 0, 1, 1),
+		"9/0_0.png": image.Rect(0, 0, 255, 200),
+		"9/1_0.png": image.Rect(0, 0, 47, 200),
+	}
+	for name, bounds := range tiles {
+		f, err := os.Open(filepath.Join(dir, "out_files", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if img.Bounds() != bounds {
+			t.Errorf("tile %s has bounds %v, expected %v", name, img.Bounds(),
+				bounds)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out_files", "10")); err == nil {
+		t.Error("pyramid has too many levels")
+	}
+}
+
+func TestRenderCommandDomain(t *testing.T) {
+	rand.Seed(0)
+
+	g := NewGenome(0, 4, 4, 3)
+	for i := 0; i < 20; i++ {
+		g.Mutate(0.5, 0.5)
+	}
+	config := DefaultConfiguration()
+	config.Width, config.Height = 8, 8
+	dir := t.TempDir()
+	genome := filepath.Join(dir, "genome.json")
+	if err := WriteGenome(genome, g, NewGenomeMeta(g, config)); err != nil {
+		t.Fatal(err)
+	}
+	legacy := filepath.Join(dir, "genome.txt")
+	if err := WriteGenome(legacy, g, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "out.png")
+	load := func(args ...string) image.Image {
+		if err := renderCommand(args); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Open(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		img, err := png.Decode(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return img
+	}
+	img1 := load(genome, out, "8", "8")
+
+	// pixels are sampled at their corners, as in training, so downsampling a
+	// render of twice the size by taking every other pixel gives the same
+	// image if the domain is the training size
+	for name, img2 := range map[string]image.Image{
+		"metadata": load(genome, out, "16", "16"),
+		"flag":     load("-domain", "8x8", legacy, out, "16", "16"),
+	} {
+		for y := 0; y < 8; y++ {
+			for x := 0; x < 8; x++ {
+				if c1, c2 := img1.At(x, y), img2.At(2*x, 2*y); c1 != c2 {
+					t.Fatalf("downsampled %s render is %v at (%d, %d), "+
+						"expected %v", name, c2, x, y, c1)
+				}
+			}
+		}
+	}
+
+	if err := renderCommand([]string{"-domain", "8", legacy, out, "16",
+		"16"}); err == nil {
+		t.Error("render accepts an invalid domain")
+	}
+}
@@ -0,0 +1,225 @@
+/*
+
+
+validate.go implementation of invariant checks of genomes.
+
+@licstart   The following is the entire license notice for
+the Go code in this page.
+
+Copyright (C) 2017 jin yeom
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+As additional permission under GNU GPL version 3 section 7, you
+may distribute non-source (e.g., minimized or compacted) forms of
+that code without the copy of the GNU GPL normally required by
+section 4, provided you include this license notice and a URL
+through which recipients can access the Corresponding Source.
+
+@licend    The above is the entire license notice
+for the Go code in this page.
+
+
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ValidationError lists the invariants a genome violates.
+type ValidationError struct {
+	ID       int      // genome ID
+	Problems []string // violated invariants
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid genome %d: %s", e.ID,
+		strings.Join(e.Problems, "; "))
+}
+
+// Validate checks the genome's invariants: node IDs are unique, edges only
+// connect nodes in the genome, no two edges connect the same nodes, no edge
+// goes into an input node, the graph is acyclic, the node counts match the
+// node types, and every output node can be reached from an input node. It
+// returns a *ValidationError listing the violations, if any.
+func (g *Genome) Validate() error {
+	problems := make([]string, 0)
+
+	// nodes
+	nodes := make(map[int]*NodeGene)
+	numInputs, numOutputs, numHidden := 0, 0, 0
+	for _, node := range g.NodeGenes {
+		if _, ok := nodes[node.ID]; ok {
+			problems = append(problems,
+				fmt.Sprintf("duplicate node ID %d", node.ID))
+		}
+		nodes[node.ID] = node
+
+		switch node.Type {
+		case "input":
+			numInputs++
+		case "output":
+			numOutputs++
+		case "hidden":
+			numHidden++
+		default:
+			problems = append(problems,
+				fmt.Sprintf("node %d has invalid type %q", node.ID, node.Type))
+		}
+		if _, ok := aFuncSet[node.AFuncType]; !ok {
+			problems = append(problems, fmt.Sprintf(
+				"node %d has unknown activation %q", node.ID, node.AFuncType))
+		}
+	}
+	if numInputs != g.NumInputs {
+		problems = append(problems, fmt.Sprintf(
+			"NumInputs is %d, but there are %d input nodes",
+			g.NumInputs, numInputs))
+	}
+	if numOutputs != g.NumOutputs {
+		problems = append(problems, fmt.Sprintf(
+			"NumOutputs is %d, but there are %d output nodes",
+			g.NumOutputs, numOutputs))
+	}
+	if numHidden != g.NumHidden {
+		problems = append(problems, fmt.Sprintf(
+			"NumHidden is %d, but there are %d hidden nodes",
+			g.NumHidden, numHidden))
+	}
+
+	// edges
+	edges := make(map[edgeKey]bool)
+	for _, edge := range g.EdgeGenes {
+		from, to := edge.InputNode.ID, edge.OutputNode.ID
+		if nodes[from] != edge.InputNode || nodes[to] != edge.OutputNode {
+			problems = append(problems, fmt.Sprintf(
+				"edge %d -> %d references a node not in the genome", from, to))
+			continue
+		}
+		if edges[edgeKey{from, to}] {
+			problems = append(problems,
+				fmt.Sprintf("duplicate edge %d -> %d", from, to))
+		}
+		edges[edgeKey{from, to}] = true
+		if edge.OutputNode.Type == "input" {
+			problems = append(problems,
+				fmt.Sprintf("edge %d -> %d goes into an input node", from, to))
+		}
+	}
+
+	// structure
+	gr := newGenomeGraph(g)
+	if cycle := gr.cycle(); cycle != nil {
+		problems = append(problems, fmt.Sprintf("cycle through nodes %v", cycle))
+	}
+	fromInputs := gr.reachable("input", false)
+	for _, node := range g.NodeGenes {
+		if node.Type == "output" && !fromInputs[node.ID] {
+			problems = append(problems, fmt.Sprintf(
+				"output node %d cannot be reached from an input", node.ID))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{ID: g.ID, Problems: problems}
+	}
+	return nil
+}
+
+// cycle returns the IDs of the nodes on a cycle in the graph, or nil if the
+// graph is acyclic.
+func (gr *netGraph) cycle() []int {
+	adj := make(map[int][]int)
+	for _, e := range gr.Edges {
+		adj[e.From] = append(adj[e.From], e.To)
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[int]int)
+	path := make([]int, 0)
+
+	var visit func(id int) []int
+	visit = func(id int) []int {
+		state[id] = visiting
+		path = append(path, id)
+		for _, next := range adj[id] {
+			switch state[next] {
+			case visiting:
+				for i := range path {
+					if path[i] == next {
+						return append([]int{}, path[i:]...)
+					}
+				}
+			case unvisited:
+				if cycle := visit(next); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[id] = done
+		return nil
+	}
+
+	for _, n := range gr.Nodes {
+		if state[n.ID] == unvisited {
+			if cycle := visit(n.ID); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// debugValidate validates the argument genome after the argument operation,
+// if the configuration is in debug mode, and panics if it is invalid.
+func debugValidate(config *Configuration, g *Genome, op string) {
+	if !config.Debug {
+		return
+	}
+	if err := g.Validate(); err != nil {
+		panic(fmt.Sprintf("after %s: %s", op, err))
+	}
+}
+
+// validate checks the invariants of exported genomes.
+func validate(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: imagen validate [genome].txt ...")
+	}
+
+	numInvalid := 0
+	for i, filename := range args {
+		g, err := LoadGenome(filename, i)
+		if err != nil {
+			return err
+		}
+		if err := g.Validate(); err != nil {
+			fmt.Printf("%s: %s\n", filename, err)
+			numInvalid++
+		}
+	}
+	if numInvalid > 0 {
+		return fmt.Errorf("%d of %d genomes are invalid", numInvalid, len(args))
+	}
+	return nil
+}